@@ -21,6 +21,7 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"time"
 )
 
 // A ByteView holds an immutable view of bytes.
@@ -37,6 +38,22 @@ type ByteView struct {
 	// If b is non-nil, b is used, else s is used.
 	b []byte
 	s string
+	// e is the time the value expires. The zero value means
+	// the value never expires.
+	e time.Time
+}
+
+// Expire returns the time the view expires, or the zero time if it
+// never expires.
+// Expire 返回view的过期时间，零值表示永不过期
+func (v ByteView) Expire() time.Time {
+	return v.e
+}
+
+// expired reports whether the view has expired as of now.
+// expired 判断view在now时刻是否已过期
+func (v ByteView) expired(now time.Time) bool {
+	return !v.e.IsZero() && !now.Before(v.e)
 }
 
 // Len returns the view's length.
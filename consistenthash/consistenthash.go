@@ -14,12 +14,14 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// Package consistenthash provides an implementation of a ring hash.
+// Package consistenthash provides an implementation of a ring hash,
+// and of other consistent placements of keys on nodes.
 //这个包是一致性hash算法的实现
 package consistenthash
 
 import (
 	"hash/crc32"
+	"math"
 	"sort"
 	"strconv"
 )
@@ -27,6 +29,24 @@ import (
 // hash是一个函数类型，形参是字节切片，返回无符号32位证书0 - 2^32-1
 type Hash func(data []byte) uint32
 
+// A Placement assigns keys to nodes, so that adding or removing a
+// node moves few keys between the other nodes. Map, Rendezvous, Jump
+// and Maglev are Placements.
+// Placement接口：将key分配到节点，增删节点时尽量少移动key
+type Placement interface {
+	// Add adds nodes.
+	Add(nodes ...string)
+
+	// Remove removes nodes.
+	Remove(nodes ...string)
+
+	// Get returns the node of key, or "" if there are no nodes.
+	Get(key string) string
+
+	// IsEmpty reports whether there are no nodes.
+	IsEmpty() bool
+}
+
 // Map类型 第一个参数是hash函数 replicas 每一个cache节点的副本数
 type Map struct {
 	// Hash 上面定义的hash函数
@@ -37,6 +57,15 @@ type Map struct {
 	keys     []int // Sorted
 	// hashMap key与服务器的映射关系
 	hashMap  map[int]string
+	// nodes 所有真实节点及其权重
+	nodes    map[string]int
+	// totalWeight 所有节点的权重之和
+	totalWeight int
+
+	// loadFactor 负载上限系数，0表示不限制负载
+	loadFactor float64
+	// load 返回节点当前的负载
+	load       func(node string) int64
 }
 
 // 第一个形参为副本数，第二个为hash函数 返回map结构体指针
@@ -45,6 +74,7 @@ func New(replicas int, fn Hash) *Map {
 		replicas: replicas,
 		hash:     fn,
 		hashMap:  make(map[int]string),
+		nodes:    make(map[string]int),
 	}
 	if m.hash == nil {
 		// 默认hash函数指定
@@ -63,19 +93,68 @@ func (m *Map) IsEmpty() bool {
 // 添加cache服务器 key可以采用cache服务器ip例如 192.168.0.1,192.168.0.2
 func (m *Map) Add(keys ...string) {
 	for _, key := range keys {
-		// 根据副本数量，添加多个节点
-		for i := 0; i < m.replicas; i++ {
-			// hash函数参数为编号i连接key
-			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
-			m.keys = append(m.keys, hash)
-			// 多个节点映射到一个cache服务器
-			m.hashMap[hash] = key
-		}
+		m.add(key, 1)
 	}
 	// 升序排序这个int切片
 	sort.Ints(m.keys)
 }
 
+// AddWeighted adds a key to the hash with weight times as many
+// replicas as Add, so that it gets about weight times as many of the
+// hashed items. A key must not be added more than once.
+// 添加带权重的cache服务器，虚拟节点数为副本数乘以权重
+func (m *Map) AddWeighted(key string, weight int) {
+	if weight <= 0 {
+		panic("consistenthash: weight must be positive")
+	}
+	m.add(key, weight)
+	sort.Ints(m.keys)
+}
+
+func (m *Map) add(key string, weight int) {
+	m.nodes[key] += weight
+	m.totalWeight += weight
+	// 根据副本数量，添加多个节点
+	for i := 0; i < m.replicas*weight; i++ {
+		// hash函数参数为编号i连接key
+		hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+		m.keys = append(m.keys, hash)
+		// 多个节点映射到一个cache服务器
+		m.hashMap[hash] = key
+	}
+}
+
+// Remove removes some keys from the hash. The items of other keys
+// stay with them.
+// 移除cache服务器，只删除其虚拟节点，其余节点的映射不变
+func (m *Map) Remove(keys ...string) {
+	removed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if _, ok := m.nodes[key]; ok {
+			removed[key] = true
+			m.totalWeight -= m.nodes[key]
+			delete(m.nodes, key)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	// 原地过滤，keys仍然有序
+	kept := m.keys[:0]
+	for _, hash := range m.keys {
+		node, ok := m.hashMap[hash]
+		if !ok {
+			continue // 已删除的重复hash
+		}
+		if removed[node] {
+			delete(m.hashMap, hash)
+			continue
+		}
+		kept = append(kept, hash)
+	}
+	m.keys = kept
+}
+
 // Get gets the closest item in the hash to the provided key.
 // key为缓存数据key
 // 返回值为最近缓存服务器的key
@@ -98,8 +177,79 @@ func (m *Map) Get(key string) string {
 		idx = 0
 	}
 
+	if m.loadFactor > 0 {
+		return m.getBounded(idx)
+	}
+
 	// idx为查找到的cache节点key
 	// hashmap中存储节点与真实cache服务器的映射关系
 	// 这里的返回值为真实服务器
 	return m.hashMap[m.keys[idx]]
 }
+
+// GetN returns up to n distinct nodes for key, in order of
+// preference: the node Get returns, followed by the next nodes on the
+// ring.
+// 返回key对应的最多n个不同节点，第一个与Get相同，其余为环上顺时针的后续节点
+func (m *Map) GetN(key string, n int) []string {
+	if m.IsEmpty() || n <= 0 {
+		return nil
+	}
+	if n > len(m.nodes) {
+		n = len(m.nodes)
+	}
+	first := m.Get(key)
+	nodes := append(make([]string, 0, n), first)
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !containsString(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+func containsString(s []string, x string) bool {
+	for _, y := range s {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
+
+// SetLoadBound makes Get skip the nodes whose load is too high,
+// following the ring to the next node instead: a node may take one
+// more key while its load stays within c times the mean load of all
+// nodes, rounded up. The mean load of weighted nodes is in proportion
+// to their weight. load reports the current load of a node, such as
+// the number of requests in flight to it. A c of zero turns bounded
+// loads off; otherwise c must be at least 1.
+// 设置有界负载：负载超过平均负载c倍的节点被跳过，顺时针选择下一个节点
+func (m *Map) SetLoadBound(c float64, load func(node string) int64) {
+	if c != 0 && c < 1 {
+		panic("consistenthash: load bound factor below 1")
+	}
+	m.loadFactor = c
+	m.load = load
+}
+
+// getBounded returns the first node from the replica at idx on whose
+// load is within bounds.
+func (m *Map) getBounded(idx int) string {
+	var total int64
+	for node := range m.nodes {
+		total += m.load(node)
+	}
+	// 加上本次请求后，单位权重的负载上限
+	max := m.loadFactor * float64(total+1) / float64(m.totalWeight)
+	for i := 0; i < len(m.keys); i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if m.load(node)+1 <= int64(math.Ceil(max*float64(m.nodes[node]))) {
+			return node
+		}
+	}
+	return m.hashMap[m.keys[idx]]
+}
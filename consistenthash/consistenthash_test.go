@@ -18,6 +18,7 @@ package consistenthash
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"testing"
 )
@@ -86,6 +87,144 @@ func TestConsistency(t *testing.T) {
 
 }
 
+func TestRemove(t *testing.T) {
+	hash := New(50, nil)
+	hash.Add("a", "b", "c", "d")
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		before[key] = hash.Get(key)
+	}
+
+	// Only the keys of the removed node move.
+	hash.Remove("c", "not-a-node")
+	for key, node := range before {
+		got := hash.Get(key)
+		if got == "c" {
+			t.Fatalf("Get(%s) = c after removing c", key)
+		}
+		if node != "c" && got != node {
+			t.Errorf("Get(%s) moved from %s to %s", key, node, got)
+		}
+	}
+	if len(hash.keys) != 150 || len(hash.hashMap) != 150 {
+		t.Errorf("%d ring points, %d mapped; want 150", len(hash.keys), len(hash.hashMap))
+	}
+
+	// Adding it back restores the original placement.
+	hash.Add("c")
+	for key, node := range before {
+		if got := hash.Get(key); got != node {
+			t.Errorf("Get(%s) = %s after adding c back; want %s", key, got, node)
+		}
+	}
+
+	hash.Remove("a", "b", "c", "d")
+	if !hash.IsEmpty() {
+		t.Error("hash not empty after removing all nodes")
+	}
+}
+
+func TestBoundedLoad(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, err := strconv.Atoi(string(key))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(i)
+	})
+	// Replicas with "hashes": 2, 4, 6, 12, 14, 16, 22, 24, 26
+	hash.Add("6", "4", "2")
+	loads := map[string]int64{}
+	hash.SetLoadBound(1.25, func(node string) int64 { return loads[node] })
+
+	// Within bounds, keys go to their owners.
+	if got := hash.Get("11"); got != "2" {
+		t.Errorf("Get(11) = %s; want 2", got)
+	}
+
+	// An owner at its bound overflows to the next node on the ring.
+	loads["2"] = 2 // bound: ceil(1.25 * 3 / 3) = 2
+	if got := hash.Get("11"); got != "4" {
+		t.Errorf("Get(11) with owner overloaded = %s; want 4", got)
+	}
+	loads["4"] = 2 // bound: ceil(1.25 * 5 / 3) = 3
+	if got := hash.Get("11"); got != "2" {
+		t.Errorf("Get(11) with all but one at mean = %s; want 2", got)
+	}
+	loads["2"], loads["4"] = 10, 10 // bound: ceil(1.25 * 21 / 3) = 9
+	if got := hash.Get("11"); got != "6" {
+		t.Errorf("Get(11) with two overloaded = %s; want 6", got)
+	}
+
+	hash.SetLoadBound(0, nil)
+	if got := hash.Get("11"); got != "2" {
+		t.Errorf("Get(11) without bounds = %s; want 2", got)
+	}
+}
+
+func TestBoundedLoadSpread(t *testing.T) {
+	hash := New(50, nil)
+	var nodes []string
+	for i := 0; i < 8; i++ {
+		nodes = append(nodes, fmt.Sprintf("node-%d", i))
+	}
+	hash.Add(nodes...)
+	loads := map[string]int64{}
+	const c = 1.25
+	hash.SetLoadBound(c, func(node string) int64 { return loads[node] })
+
+	// Keys assigned, and kept in flight, never load a node above
+	// the bound, even when they are all the same hot key.
+	const n = 1000
+	for i := 0; i < n; i++ {
+		loads[hash.Get("hot")]++
+	}
+	const max = 157 // ceil(c * n / 8)
+	for node, load := range loads {
+		if load > max {
+			t.Errorf("%s has load %d; want at most %d", node, load, max)
+		}
+	}
+}
+
+func TestWeightedSkew(t *testing.T) {
+	hash := New(50, nil)
+	weights := map[string]int{"small": 1, "medium": 2, "large": 4}
+	var totalWeight int
+	for node, weight := range weights {
+		hash.AddWeighted(node, weight)
+		totalWeight += weight
+	}
+	const n = 100000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[hash.Get("key-"+strconv.Itoa(i))]++
+	}
+	for node, weight := range weights {
+		want := float64(n*weight) / float64(totalWeight)
+		skew := (float64(counts[node]) - want) / want
+		t.Logf("%s (weight %d): %d keys, skew %+.3f", node, weight, counts[node], skew)
+		if skew < -0.2 || skew > 0.2 {
+			t.Errorf("%s got %d of %d keys; want about %.0f", node, counts[node], n, want)
+		}
+	}
+}
+
+func TestWeightedBoundedLoad(t *testing.T) {
+	hash := New(50, nil)
+	hash.AddWeighted("small", 1)
+	hash.AddWeighted("large", 3)
+	loads := map[string]int64{}
+	hash.SetLoadBound(1, func(node string) int64 { return loads[node] })
+	for i := 0; i < 400; i++ {
+		loads[hash.Get("hot")]++
+	}
+	if loads["small"] != 100 || loads["large"] != 300 {
+		t.Errorf("loads = %v; want 100 small and 300 large", loads)
+	}
+}
+
 func BenchmarkGet8(b *testing.B)   { benchmarkGet(b, 8) }
 func BenchmarkGet32(b *testing.B)  { benchmarkGet(b, 32) }
 func BenchmarkGet128(b *testing.B) { benchmarkGet(b, 128) }
@@ -108,3 +247,248 @@ func benchmarkGet(b *testing.B, shards int) {
 		hash.Get(buckets[i&(shards-1)])
 	}
 }
+
+// placements returns a Placement of each kind.
+func placements() map[string]func() Placement {
+	return map[string]func() Placement{
+		"ring":       func() Placement { return New(50, nil) },
+		"rendezvous": func() Placement { return NewRendezvous(nil) },
+		"jump":       func() Placement { return NewJump(nil) },
+		"maglev":     func() Placement { return NewMaglev(0, nil) },
+	}
+}
+
+func nodeNames(n int) []string {
+	var nodes []string
+	for i := 0; i < n; i++ {
+		nodes = append(nodes, fmt.Sprintf("node-%d", i))
+	}
+	return nodes
+}
+
+func TestPlacementConsistency(t *testing.T) {
+	for name, newPlacement := range placements() {
+		if name == "jump" {
+			continue // buckets depend on the order of Add
+		}
+		p1, p2 := newPlacement(), newPlacement()
+		p1.Add("Bill", "Bob", "Bonny")
+		p2.Add("Bob", "Bonny", "Bill")
+		for i := 0; i < 100; i++ {
+			key := strconv.Itoa(i)
+			if p1.Get(key) != p2.Get(key) {
+				t.Errorf("%s: Get(%s) depends on the order of Add", name, key)
+			}
+		}
+	}
+}
+
+func TestPlacementSkew(t *testing.T) {
+	maxSkew := map[string]float64{
+		"ring":       0.35, // depends on the number of replicas
+		"rendezvous": 0.05,
+		"jump":       0.05,
+		"maglev":     0.05,
+	}
+	nodes := nodeNames(10)
+	const n = 100000
+	for name, newPlacement := range placements() {
+		p := newPlacement()
+		p.Add(nodes...)
+		counts := make(map[string]int)
+		for i := 0; i < n; i++ {
+			counts[p.Get("key-"+strconv.Itoa(i))]++
+		}
+		want := float64(n) / float64(len(nodes))
+		var worst float64
+		for _, node := range nodes {
+			skew := math.Abs(float64(counts[node])-want) / want
+			worst = math.Max(worst, skew)
+		}
+		t.Logf("%s: worst skew %.3f", name, worst)
+		if worst > maxSkew[name] {
+			t.Errorf("%s: worst skew %.3f; want at most %.2f", name, worst, maxSkew[name])
+		}
+	}
+}
+
+func TestPlacementMovement(t *testing.T) {
+	nodes := nodeNames(10)
+	const n = 10000
+	for name, newPlacement := range placements() {
+		p := newPlacement()
+		p.Add(nodes[:9]...)
+		before := make([]string, n)
+		for i := range before {
+			before[i] = p.Get(strconv.Itoa(i))
+		}
+
+		// Adding a node moves about 1/10 of the keys, all to it.
+		// Maglev may also shuffle a few keys between other nodes.
+		p.Add(nodes[9])
+		moved, strays := 0, 0
+		for i, node := range before {
+			got := p.Get(strconv.Itoa(i))
+			if got == node {
+				continue
+			}
+			moved++
+			if got != nodes[9] {
+				strays++
+			}
+		}
+		t.Logf("%s: adding moved %d keys, %d between old nodes", name, moved, strays)
+		if moved < n/20 || moved > n/5 {
+			t.Errorf("%s: adding a tenth node moved %d of %d keys", name, moved, n)
+		}
+		if strays > 0 && name != "maglev" || strays > n/50 {
+			t.Errorf("%s: adding a node moved %d keys between old nodes", name, strays)
+		}
+
+		// Removing it puts them back.
+		p.Remove(nodes[9])
+		for i, node := range before {
+			if got := p.Get(strconv.Itoa(i)); got != node {
+				t.Errorf("%s: Get(%d) = %s after removing the new node; want %s", name, i, got, node)
+				break
+			}
+		}
+
+		// Removing another node moves only its keys, except that
+		// jump also moves those of the last node.
+		p.Remove(nodes[3])
+		strays = 0
+		for i, node := range before {
+			got := p.Get(strconv.Itoa(i))
+			if got == nodes[3] {
+				t.Fatalf("%s: Get(%d) = %s after removing it", name, i, got)
+			}
+			if node != nodes[3] && got != node && !(name == "jump" && node == nodes[8]) {
+				strays++
+			}
+		}
+		if strays > 0 && name != "maglev" || strays > n/50 {
+			t.Errorf("%s: removing a node moved %d other keys", name, strays)
+		}
+
+		p.Remove(nodes...)
+		if !p.IsEmpty() || p.Get("x") != "" {
+			t.Errorf("%s: not empty after removing all nodes", name)
+		}
+	}
+}
+
+func TestPlacementGetN(t *testing.T) {
+	nodes := nodeNames(5)
+	for name, newPlacement := range placements() {
+		p := newPlacement()
+		p.Add(nodes...)
+		getN := p.(interface{ GetN(string, int) []string })
+		for i := 0; i < 1000; i++ {
+			key := strconv.Itoa(i)
+			owners := getN.GetN(key, 3)
+			if len(owners) != 3 || owners[0] != p.Get(key) {
+				t.Fatalf("%s: GetN(%s, 3) = %v; want 3 nodes starting with %s", name, key, owners, p.Get(key))
+			}
+			if owners[1] == owners[0] || owners[2] == owners[0] || owners[1] == owners[2] {
+				t.Fatalf("%s: GetN(%s, 3) = %v; want distinct nodes", name, key, owners)
+			}
+		}
+		if owners := getN.GetN("x", 10); len(owners) != len(nodes) {
+			t.Errorf("%s: GetN(x, 10) = %v; want all %d nodes", name, owners, len(nodes))
+		}
+	}
+
+	// With the ring and rendezvous hashing, a key whose first node is
+	// removed goes to its second.
+	for _, name := range []string{"ring", "rendezvous"} {
+		p := placements()[name]()
+		p.Add(nodes...)
+		getN := p.(interface{ GetN(string, int) []string })
+		before := make(map[string][]string)
+		for i := 0; i < 1000; i++ {
+			key := strconv.Itoa(i)
+			before[key] = getN.GetN(key, 2)
+		}
+		p.Remove(nodes[0])
+		for key, owners := range before {
+			if owners[0] == nodes[0] && p.Get(key) != owners[1] {
+				t.Errorf("%s: Get(%s) = %s after removing %s; want %s", name, key, p.Get(key), nodes[0], owners[1])
+			}
+		}
+	}
+}
+
+func TestRendezvousWeighted(t *testing.T) {
+	r := NewRendezvous(nil)
+	r.AddWeighted("small", 1)
+	r.AddWeighted("large", 3)
+	const n = 40000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[r.Get(strconv.Itoa(i))]++
+	}
+	if counts["large"] < 2*counts["small"] || counts["large"] > 4*counts["small"] {
+		t.Errorf("counts = %v; want about three times as many large", counts)
+	}
+}
+
+func TestMaglevSize(t *testing.T) {
+	for _, tt := range []struct{ size, want int }{
+		{0, DefaultMaglevSize},
+		{1, 2},
+		{2, 2},
+		{1000, 1009},
+		{1009, 1009},
+	} {
+		m := NewMaglev(tt.size, nil)
+		if int(m.size) != tt.want {
+			t.Errorf("NewMaglev(%d) has a table of %d entries; want %d", tt.size, m.size, tt.want)
+		}
+		// Every node gets entries, which a table of a size that
+		// is not prime does not ensure.
+		nodes := nodeNames(2)
+		if tt.size >= 1000 {
+			nodes = nodeNames(16)
+		}
+		m.Add(nodes...)
+		owned := make(map[int]bool)
+		for _, b := range m.table {
+			owned[b] = true
+		}
+		if len(owned) != len(nodes) {
+			t.Errorf("NewMaglev(%d): %d of %d nodes own entries", tt.size, len(owned), len(nodes))
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewMaglev(-1) did not panic")
+		}
+	}()
+	NewMaglev(-1, nil)
+}
+
+func BenchmarkRendezvousGet8(b *testing.B)   { benchmarkPlacementGet(b, NewRendezvous(nil), 8) }
+func BenchmarkRendezvousGet32(b *testing.B)  { benchmarkPlacementGet(b, NewRendezvous(nil), 32) }
+func BenchmarkRendezvousGet128(b *testing.B) { benchmarkPlacementGet(b, NewRendezvous(nil), 128) }
+func BenchmarkRendezvousGet512(b *testing.B) { benchmarkPlacementGet(b, NewRendezvous(nil), 512) }
+
+func BenchmarkJumpGet8(b *testing.B)   { benchmarkPlacementGet(b, NewJump(nil), 8) }
+func BenchmarkJumpGet32(b *testing.B)  { benchmarkPlacementGet(b, NewJump(nil), 32) }
+func BenchmarkJumpGet128(b *testing.B) { benchmarkPlacementGet(b, NewJump(nil), 128) }
+func BenchmarkJumpGet512(b *testing.B) { benchmarkPlacementGet(b, NewJump(nil), 512) }
+
+func BenchmarkMaglevGet8(b *testing.B)   { benchmarkPlacementGet(b, NewMaglev(0, nil), 8) }
+func BenchmarkMaglevGet32(b *testing.B)  { benchmarkPlacementGet(b, NewMaglev(0, nil), 32) }
+func BenchmarkMaglevGet128(b *testing.B) { benchmarkPlacementGet(b, NewMaglev(0, nil), 128) }
+func BenchmarkMaglevGet512(b *testing.B) { benchmarkPlacementGet(b, NewMaglev(0, nil), 512) }
+
+func benchmarkPlacementGet(b *testing.B, p Placement, shards int) {
+	buckets := nodeNames(shards)
+	p.Add(buckets...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Get(buckets[i&(shards-1)])
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import "hash/crc32"
+
+// Jump is a Placement using jump consistent hashing (Lamping and
+// Veach, 2014), which spreads keys evenly over numbered buckets with
+// no memory beyond the list of nodes.
+//
+// Buckets are numbered in the order nodes are added, so every process
+// must add the same nodes in the same order. Removing a node moves the
+// last node into its bucket, moving the keys of both.
+// 跳跃一致性哈希：节点按添加顺序编号，各进程必须以相同顺序添加节点
+type Jump struct {
+	hash  Hash
+	nodes []string
+	index map[string]int
+}
+
+// NewJump creates a Jump using fn, or crc32.ChecksumIEEE if fn is
+// nil.
+func NewJump(fn Hash) *Jump {
+	if fn == nil {
+		fn = crc32.ChecksumIEEE
+	}
+	return &Jump{hash: fn, index: make(map[string]int)}
+}
+
+// IsEmpty returns true if there are no nodes.
+func (j *Jump) IsEmpty() bool {
+	return len(j.nodes) == 0
+}
+
+// Add adds nodes, in order, after the nodes already added.
+func (j *Jump) Add(nodes ...string) {
+	for _, node := range nodes {
+		if _, ok := j.index[node]; ok {
+			continue
+		}
+		j.index[node] = len(j.nodes)
+		j.nodes = append(j.nodes, node)
+	}
+}
+
+// Remove removes nodes, moving the last node into the bucket of each.
+// The buckets then depend on the order of the calls of Add and Remove:
+// a Jump newly built from the remaining nodes may place keys elsewhere.
+func (j *Jump) Remove(nodes ...string) {
+	for _, node := range nodes {
+		i, ok := j.index[node]
+		if !ok {
+			continue
+		}
+		last := len(j.nodes) - 1
+		j.nodes[i] = j.nodes[last]
+		j.index[j.nodes[i]] = i
+		j.nodes = j.nodes[:last]
+		delete(j.index, node)
+	}
+}
+
+// Get returns the node of the bucket key jumps to.
+func (j *Jump) Get(key string) string {
+	if len(j.nodes) == 0 {
+		return ""
+	}
+	return j.nodes[jump(uint64(j.hash([]byte(key))), len(j.nodes))]
+}
+
+// GetN returns up to n distinct nodes for key: the node Get returns,
+// followed by the nodes of the buckets key jumps to when hashed again.
+func (j *Jump) GetN(key string, n int) []string {
+	if n > len(j.nodes) {
+		n = len(j.nodes)
+	}
+	if n <= 0 {
+		return nil
+	}
+	h := uint64(j.hash([]byte(key)))
+	nodes := make([]string, 0, n)
+	taken := make([]bool, len(j.nodes))
+	for i := uint64(0); len(nodes) < n; i++ {
+		seed := h
+		if i > 0 {
+			seed = mix64(h ^ i<<32)
+		}
+		if b := jump(seed, len(j.nodes)); !taken[b] {
+			taken[b] = true
+			nodes = append(nodes, j.nodes[b])
+		}
+	}
+	return nodes
+}
+
+// jump returns the bucket of key among n buckets.
+func jump(key uint64, n int) int {
+	var b, i int64 = -1, 0
+	for i < int64(n) {
+		b = i
+		key = key*2862933555777941757 + 1
+		i = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
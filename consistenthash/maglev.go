@@ -0,0 +1,180 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import (
+	"hash/crc32"
+	"sort"
+)
+
+// DefaultMaglevSize is the lookup table size of a Maglev created with
+// a size of zero.
+const DefaultMaglevSize = 65537
+
+// Maglev is a Placement using Maglev hashing (Eisenbud et al., 2016):
+// keys are looked up in a table in which each node takes turns to
+// claim its preferred free entries. Get takes constant time, and keys
+// are spread almost perfectly evenly, at the cost of rebuilding the
+// table on each change of nodes and of moving slightly more keys than
+// other placements do.
+// Maglev哈希：查表为常数时间，增删节点时重建查找表
+type Maglev struct {
+	hash  Hash
+	size  uint64
+	nodes []string // sorted
+	table []int    // indices into nodes; nil if there are none
+}
+
+// NewMaglev creates a Maglev with a lookup table of size entries,
+// using fn, or crc32.ChecksumIEEE if fn is nil. size should be well
+// above 100 times the number of nodes; if zero, it defaults to
+// DefaultMaglevSize. It is rounded up to a prime, which the
+// permutations of the nodes need to cover the whole table, and it
+// must not be negative.
+func NewMaglev(size int, fn Hash) *Maglev {
+	if size < 0 {
+		panic("consistenthash: negative Maglev size")
+	}
+	if size == 0 {
+		size = DefaultMaglevSize
+	}
+	size = nextPrime(size)
+	if fn == nil {
+		fn = crc32.ChecksumIEEE
+	}
+	return &Maglev{hash: fn, size: uint64(size)}
+}
+
+// IsEmpty returns true if there are no nodes.
+func (m *Maglev) IsEmpty() bool {
+	return len(m.nodes) == 0
+}
+
+// Add adds nodes.
+func (m *Maglev) Add(nodes ...string) {
+	for _, node := range nodes {
+		i := sort.SearchStrings(m.nodes, node)
+		if i < len(m.nodes) && m.nodes[i] == node {
+			continue
+		}
+		m.nodes = append(m.nodes, "")
+		copy(m.nodes[i+1:], m.nodes[i:])
+		m.nodes[i] = node
+	}
+	m.populate()
+}
+
+// Remove removes nodes.
+func (m *Maglev) Remove(nodes ...string) {
+	for _, node := range nodes {
+		i := sort.SearchStrings(m.nodes, node)
+		if i < len(m.nodes) && m.nodes[i] == node {
+			m.nodes = append(m.nodes[:i], m.nodes[i+1:]...)
+		}
+	}
+	m.populate()
+}
+
+// Get returns the node of the table entry of key.
+func (m *Maglev) Get(key string) string {
+	if len(m.nodes) == 0 {
+		return ""
+	}
+	return m.nodes[m.table[uint64(m.hash([]byte(key)))%m.size]]
+}
+
+// GetN returns up to n distinct nodes for key: the node Get returns,
+// followed by the nodes of the next table entries.
+func (m *Maglev) GetN(key string, n int) []string {
+	if n > len(m.nodes) {
+		n = len(m.nodes)
+	}
+	if n <= 0 {
+		return nil
+	}
+	nodes := make([]string, 0, n)
+	taken := make([]bool, len(m.nodes))
+	c := uint64(m.hash([]byte(key))) % m.size
+	for i := uint64(0); i < m.size && len(nodes) < n; i++ {
+		if b := m.table[(c+i)%m.size]; !taken[b] {
+			taken[b] = true
+			nodes = append(nodes, m.nodes[b])
+		}
+	}
+	return nodes
+}
+
+// populate fills the lookup table. Each node has a permutation of the
+// entries, given by an offset and a skip from hashes of its name, and
+// the nodes take turns to claim the next free entry in theirs.
+// 各节点按自身的排列轮流占据查找表中下一个空位
+func (m *Maglev) populate() {
+	n := len(m.nodes)
+	if n == 0 {
+		m.table = nil
+		return
+	}
+	offsets := make([]uint64, n)
+	skips := make([]uint64, n)
+	next := make([]uint64, n)
+	for i, node := range m.nodes {
+		offsets[i] = uint64(m.hash([]byte("offset:"+node))) % m.size
+		skips[i] = uint64(m.hash([]byte("skip:"+node)))%(m.size-1) + 1
+	}
+	table := make([]int, m.size)
+	for i := range table {
+		table[i] = -1
+	}
+	for filled := uint64(0); ; {
+		for i := 0; i < n; i++ {
+			c := (offsets[i] + next[i]*skips[i]) % m.size
+			for table[c] >= 0 {
+				next[i]++
+				c = (offsets[i] + next[i]*skips[i]) % m.size
+			}
+			table[c] = i
+			next[i]++
+			if filled++; filled == m.size {
+				m.table = table
+				return
+			}
+		}
+	}
+}
+
+// nextPrime returns the least prime at least n.
+// 返回不小于n的最小素数
+func nextPrime(n int) int {
+	if n <= 2 {
+		return 2
+	}
+	if n%2 == 0 {
+		n++
+	}
+	for ; ; n += 2 {
+		prime := true
+		for d := 3; d*d <= n; d += 2 {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
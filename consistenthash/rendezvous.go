@@ -0,0 +1,159 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import (
+	"hash/crc32"
+	"math"
+	"sort"
+)
+
+// Rendezvous is a Placement using rendezvous, or highest random
+// weight, hashing: a key goes to the node for which a mix of the hash
+// of the node and that of the key is highest. Keys are spread evenly without virtual
+// nodes, but Get takes time proportional to the number of nodes.
+// 最高随机权重(HRW)哈希，无需虚拟节点，Get的时间与节点数成正比
+type Rendezvous struct {
+	hash    Hash
+	nodes   []string // sorted
+	hashes  []uint32 // of nodes
+	weights map[string]int
+	uneven  int // number of nodes of weight other than 1
+}
+
+// NewRendezvous creates a Rendezvous using fn, or crc32.ChecksumIEEE
+// if fn is nil.
+func NewRendezvous(fn Hash) *Rendezvous {
+	if fn == nil {
+		fn = crc32.ChecksumIEEE
+	}
+	return &Rendezvous{hash: fn, weights: make(map[string]int)}
+}
+
+// IsEmpty returns true if there are no nodes.
+func (r *Rendezvous) IsEmpty() bool {
+	return len(r.nodes) == 0
+}
+
+// Add adds nodes of weight 1.
+func (r *Rendezvous) Add(nodes ...string) {
+	for _, node := range nodes {
+		r.AddWeighted(node, 1)
+	}
+}
+
+// AddWeighted adds a node which gets about weight times as many keys
+// as a node of weight 1.
+func (r *Rendezvous) AddWeighted(node string, weight int) {
+	if weight <= 0 {
+		panic("consistenthash: weight must be positive")
+	}
+	if _, ok := r.weights[node]; !ok {
+		i := sort.SearchStrings(r.nodes, node)
+		r.nodes = append(r.nodes, "")
+		copy(r.nodes[i+1:], r.nodes[i:])
+		r.nodes[i] = node
+		r.hashes = append(r.hashes, 0)
+		copy(r.hashes[i+1:], r.hashes[i:])
+		r.hashes[i] = r.hash([]byte(node))
+	} else if r.weights[node] != 1 {
+		r.uneven--
+	}
+	if weight != 1 {
+		r.uneven++
+	}
+	r.weights[node] = weight
+}
+
+// Remove removes nodes. Only their keys move.
+func (r *Rendezvous) Remove(nodes ...string) {
+	for _, node := range nodes {
+		w, ok := r.weights[node]
+		if !ok {
+			continue
+		}
+		if w != 1 {
+			r.uneven--
+		}
+		delete(r.weights, node)
+		i := sort.SearchStrings(r.nodes, node)
+		r.nodes = append(r.nodes[:i], r.nodes[i+1:]...)
+		r.hashes = append(r.hashes[:i], r.hashes[i+1:]...)
+	}
+}
+
+// Get returns the node with the highest score for key.
+func (r *Rendezvous) Get(key string) string {
+	kh := uint64(r.hash([]byte(key)))
+	if r.uneven == 0 {
+		// With equal weights the highest hash wins.
+		var best string
+		var bestHash uint64
+		for i, node := range r.nodes {
+			if h := mix64(uint64(r.hashes[i])<<32 | kh); best == "" || h > bestHash {
+				best, bestHash = node, h
+			}
+		}
+		return best
+	}
+	best, bestScore := "", math.Inf(-1)
+	for i, node := range r.nodes {
+		// Map the mixed hashes into (0, 1); -w/ln(x) makes a node of
+		// weight w win w times as often as a node of weight 1.
+		h := mix64(uint64(r.hashes[i])<<32 | kh)
+		x := (float64(h>>11) + 0.5) / (1 << 53)
+		score := float64(r.weights[node]) / -math.Log(x)
+		if score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	return best
+}
+
+// GetN returns up to n distinct nodes for key, in order of
+// decreasing score.
+func (r *Rendezvous) GetN(key string, n int) []string {
+	if n > len(r.nodes) {
+		n = len(r.nodes)
+	}
+	if n <= 0 {
+		return nil
+	}
+	kh := uint64(r.hash([]byte(key)))
+	scores := make([]float64, len(r.nodes))
+	order := make([]int, len(r.nodes))
+	for i, node := range r.nodes {
+		h := mix64(uint64(r.hashes[i])<<32 | kh)
+		x := (float64(h>>11) + 0.5) / (1 << 53)
+		scores[i] = float64(r.weights[node]) / -math.Log(x)
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = r.nodes[order[i]]
+	}
+	return nodes
+}
+
+// mix64 is the finalizer of SplitMix64, which spreads each input bit
+// over all output bits.
+func mix64(h uint64) uint64 {
+	h = (h ^ h>>30) * 0xbf58476d1ce4e5b9
+	h = (h ^ h>>27) * 0x94d049bb133111eb
+	return h ^ h>>31
+}
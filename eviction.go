@@ -0,0 +1,77 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"groupcache/lfu"
+	"groupcache/lru"
+	"groupcache/tinylfu"
+	"groupcache/twoq"
+)
+
+// An EvictionPolicy holds the entries of one of a Group's caches and
+// chooses which entry to evict when the group is over its size limit.
+// Calls are serialized by the cache.
+type EvictionPolicy interface {
+	// Add adds or replaces the value of key.
+	Add(key string, value ByteView)
+
+	// Get returns the value of key, counting as a use of it.
+	Get(key string) (value ByteView, ok bool)
+
+	// Peek returns the value of key without counting as a use of it.
+	Peek(key string) (value ByteView, ok bool)
+
+	// Remove removes key, if present.
+	Remove(key string)
+
+	// RemoveOldest removes the entry the policy values least.
+	RemoveOldest()
+
+	// Len returns the number of entries.
+	Len() int
+}
+
+// An Eviction creates the EvictionPolicy of a cache. The policy must
+// call onEvicted with each entry it removes, whether by Remove or by
+// RemoveOldest.
+type Eviction func(onEvicted func(key string, value ByteView)) EvictionPolicy
+
+// LRU evicts the least recently used entry. It is the default.
+func LRU(onEvicted func(key string, value ByteView)) EvictionPolicy {
+	return &lru.Cache[string, ByteView]{OnEvicted: onEvicted}
+}
+
+// LFU evicts the least frequently used entry, and the least recently
+// used one among those used equally often.
+func LFU(onEvicted func(key string, value ByteView)) EvictionPolicy {
+	return &lfu.Cache[string, ByteView]{OnEvicted: onEvicted}
+}
+
+// TwoQueue evicts entries used only once before entries used again,
+// so that scans over many keys don't flush the cache. See package
+// twoq.
+func TwoQueue(onEvicted func(key string, value ByteView)) EvictionPolicy {
+	return &twoq.Cache[string, ByteView]{OnEvicted: onEvicted}
+}
+
+// TinyLFU admits new entries into the cache by their estimated
+// frequency of use, evicting new entries rather than more popular
+// ones. See package tinylfu.
+func TinyLFU(onEvicted func(key string, value ByteView)) EvictionPolicy {
+	return &tinylfu.Cache[string, ByteView]{OnEvicted: onEvicted}
+}
@@ -27,27 +27,80 @@ package groupcache
 import (
 	"context"
 	"errors"
-	"math/rand"
+	"fmt"
+	"runtime"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
-	pb "github.com/golang/groupcache/groupcachepb"
-	"github.com/golang/groupcache/lru"
-	"github.com/golang/groupcache/singleflight"
+	"github.com/golang/protobuf/proto"
+	pb "groupcache/groupcachepb"
+	"groupcache/lru"
+	"groupcache/singleflight"
 )
 
 // A Getter loads data for a key.
 type Getter interface {
 	// Get returns the value identified by key, populating dest.
 	//
-	// The returned data must be unversioned. That is, key must
+	// The returned data should be unversioned. That is, key should
 	// uniquely describe the loaded data, without an implicit
-	// current time, and without relying on cache expiration
-	// mechanisms.
+	// current time. Data that does change over time may be given
+	// an expiry with dest.SetExpire, or a group-wide TTL with
+	// GroupOptions.TTL, after which it is loaded again.
 	Get(ctx context.Context, key string, dest Sink) error
 }
 
+// ErrNotFound may be returned, possibly wrapped, by a Getter when key
+// has no value. Groups with a NegativeTTL remember such errors for a
+// while instead of calling the Getter again.
+var ErrNotFound = errors.New("groupcache: not found")
+
+// An ErrorCode classifies an Error.
+type ErrorCode int32
+
+const (
+	// CodeUnknown is the code of errors not otherwise classified.
+	CodeUnknown ErrorCode = iota
+
+	// CodeNotFound is the code of errors matching ErrNotFound.
+	CodeNotFound
+)
+
+// An Error is an error returned by the Getter of a key's owner, as
+// seen by the peer that asked the owner for the key. A Getter may
+// also return an *Error itself to choose the code its peers see.
+// Codes other than the ones defined by this package are up to the
+// application.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether e matches target. An Error with CodeNotFound
+// matches ErrNotFound.
+func (e *Error) Is(target error) bool {
+	return target == ErrNotFound && e.Code == CodeNotFound
+}
+
+// errorCode returns the code peers see for err.
+func errorCode(err error) ErrorCode {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	if errors.Is(err, ErrNotFound) {
+		return CodeNotFound
+	}
+	return CodeUnknown
+}
+
 // A GetterFunc implements Getter with a function.
 type GetterFunc func(ctx context.Context, key string, dest Sink) error
 
@@ -55,23 +108,67 @@ func (f GetterFunc) Get(ctx context.Context, key string, dest Sink) error {
 	return f(ctx, key, dest)
 }
 
-var (
+// A Workspace is an independent set of groups, together with the
+// peer picker and HTTP pool that serve them. Separate workspaces allow
+// several caches to run in one process, e.g. in tests. The
+// package-level functions use DefaultWorkspace.
+type Workspace struct {
 	mu     sync.RWMutex
-	groups = make(map[string]*Group)
+	groups map[string]*Group
 
 	initPeerServerOnce sync.Once
 	initPeerServer     func()
-)
+
+	// newGroupHook, if non-nil, is called right after a new group is created.
+	newGroupHook func(*Group)
+
+	portPicker func(groupName string) PeerPicker
+	httpPool   *HTTPPool // made by NewHTTPPoolOpts, if any; guarded by mu
+}
+
+// DefaultWorkspace is the workspace used by NewGroup, GetGroup,
+// NewHTTPPool and the other package-level functions.
+var DefaultWorkspace = NewWorkspace()
+
+// NewWorkspace returns a new, empty workspace.
+func NewWorkspace() *Workspace {
+	return &Workspace{
+		groups: make(map[string]*Group),
+	}
+}
 
 // GetGroup returns the named group previously created with NewGroup, or
 // nil if there's no such group.
 func GetGroup(name string) *Group {
-	mu.RLock()
-	g := groups[name]
-	mu.RUnlock()
+	return DefaultWorkspace.GetGroup(name)
+}
+
+// GetGroup returns the named group previously created in ws, or nil
+// if there's no such group.
+func (ws *Workspace) GetGroup(name string) *Group {
+	ws.mu.RLock()
+	g := ws.groups[name]
+	ws.mu.RUnlock()
 	return g
 }
 
+// Groups returns the groups created with NewGroup, sorted by name.
+func Groups() []*Group {
+	return DefaultWorkspace.Groups()
+}
+
+// Groups returns the groups created in ws, sorted by name.
+func (ws *Workspace) Groups() []*Group {
+	ws.mu.RLock()
+	groups := make([]*Group, 0, len(ws.groups))
+	for _, g := range ws.groups {
+		groups = append(groups, g)
+	}
+	ws.mu.RUnlock()
+	sort.Slice(groups, func(i, j int) bool { return groups[i].name < groups[j].name })
+	return groups
+}
+
 // NewGroup creates a coordinated group-aware Getter from a Getter.
 //
 // The returned Getter tries (but does not guarantee) to run only one
@@ -82,69 +179,223 @@ func GetGroup(name string) *Group {
 //
 // The group name must be unique for each getter.
 func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
-	return newGroup(name, cacheBytes, getter, nil)
+	return DefaultWorkspace.NewGroup(name, cacheBytes, getter)
+}
+
+// NewGroup creates a coordinated group-aware Getter in ws.
+// See the package-level NewGroup.
+func (ws *Workspace) NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+	return ws.newGroupOpts(name, cacheBytes, getter, nil, nil)
+}
+
+// GroupOptions are the configurations of a Group.
+type GroupOptions struct {
+	// TTL specifies how long values loaded by the group's Getter
+	// stay in the caches when the Getter doesn't set an expiry
+	// with Sink.SetExpire.
+	// If zero, such values never expire.
+	TTL time.Duration
+
+	// NegativeTTL specifies how long negative results of the
+	// Getter, as reported by IsNegative, are remembered. Until
+	// then, Gets for the key return the same error without calling
+	// the Getter, both in this process and on peers asking it.
+	// If zero, errors are never remembered.
+	NegativeTTL time.Duration
+
+	// IsNegative reports whether an error returned by the Getter
+	// is a negative result to be remembered for NegativeTTL.
+	// If nil, errors matching ErrNotFound are negative results.
+	IsNegative func(err error) bool
+
+	// NegativeEntries specifies the maximum number of negative
+	// results remembered.
+	// If zero, it defaults to 1024.
+	NegativeEntries int
+
+	// RetryLocally reports whether a key is loaded with this
+	// process's Getter after getting err from the key's owner.
+	// If nil, transport errors are retried locally, and errors
+	// from the owner's Getter, of type *Error, are returned.
+	RetryLocally func(err error) bool
+
+	// Eviction specifies the eviction policy of the group's main
+	// and hot caches.
+	// If nil, it defaults to LRU.
+	Eviction Eviction
+
+	// CacheShards specifies the number of shards of each of the
+	// group's main and hot caches. Each shard has its own lock and
+	// eviction policy, so concurrent Gets of keys in different
+	// shards don't contend; the cache evicts from its largest shard,
+	// so eviction across shards only approximates the policy.
+	// If zero, it defaults to runtime.GOMAXPROCS(0).
+	CacheShards int
+
+	// HotCacheQPS specifies the request rate, in requests per
+	// second, at which a value fetched from the key's owner is
+	// admitted into the hotCache. The rate of a key is its rate at
+	// the owner, as reported by the owner, plus its rate in this
+	// process.
+	// If zero, it defaults to 1. If negative, every value is
+	// admitted.
+	HotCacheQPS float64
+
+	// CacheSplit decides how the group's cache bytes are split
+	// between its mainCache and its hotCache; see AdaptiveSplit.
+	// If nil, the hotCache may hold an eighth as many bytes as the
+	// mainCache.
+	CacheSplit CacheSplit
+
+	// ReplicaFillTimeout bounds how long sending a value loaded by
+	// this process to another owner of its key may take; see
+	// ReplicaPicker.
+	// If zero, it defaults to 5 seconds.
+	ReplicaFillTimeout time.Duration
+
+	// TraceLoad, if not nil, is called by each caller of a load of
+	// a key missing from the caches once the load is done, to trace
+	// how loads are shared.
+	TraceLoad func(LoadTrace)
+}
+
+// A LoadTrace describes a load of a key, as seen by one of its
+// callers. Concurrent callers share one load.
+type LoadTrace struct {
+	Key string
+
+	// Joined reports whether the caller joined a load started by
+	// another caller.
+	Joined bool
+
+	// Dups is the number of other callers which got the result of
+	// the load.
+	Dups int
+
+	// Duration is how long the caller waited for the load.
+	Duration time.Duration
+
+	// Err is the error the caller got, if any.
+	Err error
+}
+
+const (
+	defaultNegativeEntries    = 1024
+	defaultHotCacheQPS        = 1
+	defaultReplicaFillTimeout = 5 * time.Second
+)
+
+const (
+	// replicaFillWorkers is the number of values a group sends to
+	// the other owners of their keys at once.
+	replicaFillWorkers = 4
+
+	// replicaFillQueue is the number of values a group keeps
+	// waiting to be sent to the other owners of their keys. Values
+	// beyond it are dropped: the owners load the keys themselves
+	// when asked.
+	replicaFillQueue = 256
+)
+
+// NewGroupOpts creates a coordinated group-aware Getter from a Getter
+// with the given options. See NewGroup.
+func NewGroupOpts(name string, cacheBytes int64, getter Getter, o *GroupOptions) *Group {
+	return DefaultWorkspace.NewGroupOpts(name, cacheBytes, getter, o)
+}
+
+// NewGroupOpts creates a coordinated group-aware Getter in ws with the
+// given options. See the package-level NewGroup.
+func (ws *Workspace) NewGroupOpts(name string, cacheBytes int64, getter Getter, o *GroupOptions) *Group {
+	return ws.newGroupOpts(name, cacheBytes, getter, nil, o)
 }
 
 // If peers is nil, the peerPicker is called via a sync.Once to initialize it.
 func newGroup(name string, cacheBytes int64, getter Getter, peers PeerPicker) *Group {
+	return DefaultWorkspace.newGroupOpts(name, cacheBytes, getter, peers, nil)
+}
+
+func newGroupOpts(name string, cacheBytes int64, getter Getter, peers PeerPicker, o *GroupOptions) *Group {
+	return DefaultWorkspace.newGroupOpts(name, cacheBytes, getter, peers, o)
+}
+
+func (ws *Workspace) newGroupOpts(name string, cacheBytes int64, getter Getter, peers PeerPicker, o *GroupOptions) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
-	mu.Lock()
-	defer mu.Unlock()
-	initPeerServerOnce.Do(callInitPeerServer)
-	if _, dup := groups[name]; dup {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.initPeerServerOnce.Do(ws.callInitPeerServer)
+	if _, dup := ws.groups[name]; dup {
 		panic("duplicate registration of group " + name)
 	}
 	g := &Group{
+		ws:         ws,
 		name:       name,
 		getter:     getter,
 		peers:      peers,
 		cacheBytes: cacheBytes,
 		loadGroup:  &singleflight.Group{},
 	}
-	if fn := newGroupHook; fn != nil {
+	if o != nil {
+		g.opts = *o
+	}
+	g.mainCache.eviction = g.opts.Eviction
+	g.hotCache.eviction = g.opts.Eviction
+	g.mainCache.nshards = g.opts.CacheShards
+	g.hotCache.nshards = g.opts.CacheShards
+	if fn := ws.newGroupHook; fn != nil {
 		fn(g)
 	}
-	groups[name] = g
+	ws.groups[name] = g
 	return g
 }
 
-// newGroupHook, if non-nil, is called right after a new group is created.
-var newGroupHook func(*Group)
-
 // RegisterNewGroupHook registers a hook that is run each time
 // a group is created.
 func RegisterNewGroupHook(fn func(*Group)) {
-	if newGroupHook != nil {
+	DefaultWorkspace.RegisterNewGroupHook(fn)
+}
+
+// RegisterNewGroupHook registers a hook that is run each time
+// a group is created in ws.
+func (ws *Workspace) RegisterNewGroupHook(fn func(*Group)) {
+	if ws.newGroupHook != nil {
 		panic("RegisterNewGroupHook called more than once")
 	}
-	newGroupHook = fn
+	ws.newGroupHook = fn
 }
 
 // RegisterServerStart registers a hook that is run when the first
 // group is created.
 func RegisterServerStart(fn func()) {
-	if initPeerServer != nil {
+	DefaultWorkspace.RegisterServerStart(fn)
+}
+
+// RegisterServerStart registers a hook that is run when the first
+// group is created in ws.
+func (ws *Workspace) RegisterServerStart(fn func()) {
+	if ws.initPeerServer != nil {
 		panic("RegisterServerStart called more than once")
 	}
-	initPeerServer = fn
+	ws.initPeerServer = fn
 }
 
-func callInitPeerServer() {
-	if initPeerServer != nil {
-		initPeerServer()
+func (ws *Workspace) callInitPeerServer() {
+	if ws.initPeerServer != nil {
+		ws.initPeerServer()
 	}
 }
 
 // A Group is a cache namespace and associated data loaded spread over
 // a group of 1 or more machines.
 type Group struct {
+	ws         *Workspace
 	name       string
 	getter     Getter
 	peersOnce  sync.Once
 	peers      PeerPicker
 	cacheBytes int64 // limit for sum of mainCache and hotCache size
+	opts       GroupOptions
 
 	// mainCache is a cache of the keys for which this process
 	// (amongst its peers) is authoritative. That is, this cache
@@ -162,36 +413,69 @@ type Group struct {
 	// of key/value pairs that can be stored globally.
 	hotCache cache
 
+	// negCache remembers negative results, either of the Getter
+	// for keys this process owns or reported by the owners of
+	// other keys, until they expire.
+	negCache negativeCache
+
+	// rates tracks the recent request rates of keys, to tell peers
+	// how hot the keys this process owns are, and to decide which
+	// keys owned by peers are hot enough for the hotCache.
+	rates keyRates
+
+	// peerErrs keeps the last few transport errors talking to
+	// peers, for debugging.
+	peerErrs peerErrorLog
+
+	// split is the split of cacheBytes between mainCache and
+	// hotCache.
+	split splitState
+
+	// fills queues the values to send to the other owners of
+	// their keys, for replicaFillWorkers workers started on the
+	// first fill.
+	fillOnce sync.Once
+	fills    chan replicaFill
+
 	// loadGroup ensures that each key is only fetched once
 	// (either locally or remotely), regardless of the number of
 	// concurrent callers.
 	loadGroup flightGroup
 
-	_ int32 // force Stats to be 8-byte aligned on 32-bit platforms
-
 	// Stats are statistics on the group.
 	Stats Stats
+
+	// Latencies are histograms of the group's load times.
+	Latencies Latencies
 }
 
 // flightGroup is defined as an interface which flightgroup.Group
 // satisfies.  We define this so that we may test with an alternate
 // implementation.
 type flightGroup interface {
-	// Done is called when Do is done.
-	Do(key string, fn func() (interface{}, error)) (interface{}, error)
+	DoContext(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error, int)
+	Start(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (<-chan singleflight.Result, bool)
+	Forget(key string)
 }
 
 // Stats are per-group statistics.
 type Stats struct {
-	Gets           AtomicInt // any Get request, including from peers
-	CacheHits      AtomicInt // either cache was good
-	PeerLoads      AtomicInt // either remote load or remote cache hit (not an error)
-	PeerErrors     AtomicInt
-	Loads          AtomicInt // (gets - cacheHits)
-	LoadsDeduped   AtomicInt // after singleflight
-	LocalLoads     AtomicInt // total good local loads
-	LocalLoadErrs  AtomicInt // total bad local loads
-	ServerRequests AtomicInt // gets that came over the network from peers
+	Gets            AtomicInt // any Get request, including from peers
+	CacheHits       AtomicInt // either cache was good
+	PeerLoads       AtomicInt // either remote load or remote cache hit (not a transport error)
+	PeerErrors      AtomicInt // transport errors talking to peers
+	Loads           AtomicInt // (gets - cacheHits)
+	LoadsDeduped    AtomicInt // after singleflight
+	LoadsShared     AtomicInt // loads whose result went to other callers too
+	LoadWaiters     AtomicInt // callers which got the result of a load started by another caller
+	LocalLoads      AtomicInt // total good local loads
+	LocalLoadErrs   AtomicInt // total bad local loads
+	ServerRequests  AtomicInt // gets that came over the network from peers
+	NegativeHits    AtomicInt // gets answered by a remembered negative result
+	NegativeLoads   AtomicInt // negative results remembered, from local loads or peers
+	ReplicaLoads    AtomicInt // peer loads answered by a replica after the primary owner failed
+	ReplicaFills    AtomicInt // values sent to the other owners of a key by local loads and Set
+	ReplicaFillErrs AtomicInt // values which failed to reach an owner, or were dropped
 }
 
 // Name returns the name of the group.
@@ -201,7 +485,7 @@ func (g *Group) Name() string {
 
 func (g *Group) initPeers() {
 	if g.peers == nil {
-		g.peers = getPeers(g.name)
+		g.peers = g.ws.getPeers(g.name)
 	}
 }
 
@@ -211,32 +495,247 @@ func (g *Group) Get(ctx context.Context, key string, dest Sink) error {
 	if dest == nil {
 		return errors.New("groupcache: nil dest Sink")
 	}
+	g.rates.record(key, time.Now())
 	value, cacheHit := g.lookupCache(key)
 
 	if cacheHit {
 		g.Stats.CacheHits.Add(1)
 		return setSinkView(dest, value)
 	}
+	if err := g.lookupNegative(key); err != nil {
+		return err
+	}
+
+	return g.loadInto(ctx, key, dest, nil)
+}
+
+// A MultiError holds the errors of the keys of a GetMulti, in the
+// order of the keys. Keys which were got have a nil error.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	var n int
+	var first error
+	for _, err := range m {
+		if err != nil {
+			if n == 0 {
+				first = err
+			}
+			n++
+		}
+	}
+	switch n {
+	case 0:
+		return "(0 errors)"
+	case 1:
+		return first.Error()
+	case 2:
+		return first.Error() + " (and 1 other error)"
+	}
+	return fmt.Sprintf("%v (and %d other errors)", first, n-1)
+}
 
-	// Optimization to avoid double unmarshalling or copying: keep
-	// track of whether the dest was already populated. One caller
-	// (if local) will set this; the losers will not. The common
-	// case will likely be one caller.
-	destPopulated := false
-	value, destPopulated, err := g.load(ctx, key, dest)
+// GetMulti gets the values of keys into the sinks at the same
+// positions in dests. Keys owned by other peers are fetched with one
+// request per peer, of at most 1000 keys, and keys owned by this
+// process are loaded concurrently.
+// If any key fails, GetMulti returns a MultiError. If a Getter
+// panics, GetMulti panics in the caller's goroutine, as Get does.
+func (g *Group) GetMulti(ctx context.Context, keys []string, dests []Sink) error {
+	g.peersOnce.Do(g.initPeers)
+	if len(keys) != len(dests) {
+		return errors.New("groupcache: GetMulti got different numbers of keys and dests")
+	}
+	errs := make(MultiError, len(keys))
+	var (
+		local   []int
+		batches = make(map[ProtoGetter][]int)
+		now     = time.Now()
+	)
+	for i, key := range keys {
+		g.Stats.Gets.Add(1)
+		if dests[i] == nil {
+			errs[i] = errors.New("groupcache: nil dest Sink")
+			continue
+		}
+		g.rates.record(key, now)
+		if value, cacheHit := g.lookupCache(key); cacheHit {
+			g.Stats.CacheHits.Add(1)
+			errs[i] = setSinkView(dests[i], value)
+			continue
+		}
+		if errs[i] = g.lookupNegative(key); errs[i] != nil {
+			continue
+		}
+		if peer, ok := g.peers.PickPeer(key); ok {
+			if _, ok := peer.(MultiGetter); ok {
+				batches[peer] = append(batches[peer], i)
+				continue
+			}
+		}
+		local = append(local, i)
+	}
+
+	// A panic of a Getter is caught in the goroutine which saw it
+	// and raised again in the caller's, as Get would.
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		panicked interface{} // the first panic caught
+	)
+	spawn := func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					mu.Lock()
+					if panicked == nil {
+						panicked = r
+					}
+					mu.Unlock()
+				}
+			}()
+			fn()
+		}()
+	}
+	for peer, batch := range batches {
+		for len(batch) > 0 {
+			n := len(batch)
+			if n > maxGetMultiKeys {
+				n = maxGetMultiKeys
+			}
+			peer, chunk := peer, batch[:n]
+			spawn(func() { g.getMultiFromPeer(ctx, peer, keys, dests, chunk, errs) })
+			batch = batch[n:]
+		}
+	}
+	for _, i := range local {
+		i := i
+		spawn(func() { errs[i] = g.loadInto(ctx, keys[i], dests[i], nil) })
+	}
+	wg.Wait()
+	if panicked != nil {
+		panic(panicked)
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return errs
+		}
+	}
+	return nil
+}
+
+// getMultiFromPeer fetches the keys at positions batch from peer, a
+// MultiGetter owning the keys, setting their dests and errs. Keys with
+// a load in flight get its result. The others are fetched in one
+// request, each as a load of its own which later loads of the key
+// join, and those the request fails to get are retried concurrently,
+// as load retries them.
+func (g *Group) getMultiFromPeer(ctx context.Context, peer ProtoGetter, keys []string, dests []Sink, batch []int, errs []error) {
+	start := time.Now()
+	var (
+		done    = make(chan struct{}) // closed once res and reqErr are set
+		res     = &pb.GetMultiResponse{}
+		reqErr  error
+		req     = &pb.GetMultiRequest{Group: &g.name}
+		fetched = make([]int, len(batch)) // positions in req.Keys, or -1
+		chans   = make([]<-chan singleflight.Result, len(batch))
+		joined  = make([]bool, len(batch))
+	)
+	for j, i := range batch {
+		j, key := j, keys[i]
+		g.Stats.Loads.Add(1)
+		chans[j], joined[j] = g.loadGroup.Start(ctx, key, func(ctx context.Context) (interface{}, error) {
+			<-done
+			err := reqErr
+			if err == nil {
+				g.Stats.PeerLoads.Add(1)
+				value, err := g.peerValue(key, res.Responses[fetched[j]])
+				if err == nil || !g.retryLocally(err) {
+					return value, err
+				}
+				return g.fetch(ctx, key, peer)
+			}
+			if ctx.Err() == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+				// The caller gave up, but others wait for key.
+				return g.fetch(ctx, key, nil)
+			}
+			if !g.retryLocally(err) {
+				return nil, err
+			}
+			return g.fetch(ctx, key, peer)
+		})
+		fetched[j] = -1
+		if !joined[j] {
+			fetched[j] = len(req.Keys)
+			req.Keys = append(req.Keys, key)
+		}
+	}
+
+	if len(req.Keys) > 0 {
+		g.Stats.LoadsDeduped.Add(int64(len(req.Keys)))
+		reqStart := time.Now()
+		reqErr = peer.(MultiGetter).GetMulti(ctx, req, res)
+		elapsed := time.Since(reqStart)
+		g.Latencies.PeerFetches.Observe(elapsed)
+		if reqErr == nil && len(res.Responses) != len(req.Keys) {
+			reqErr = fmt.Errorf("groupcache: peer returned %d values for %d keys", len(res.Responses), len(req.Keys))
+		}
+		if reqErr != nil {
+			g.Stats.PeerErrors.Add(1)
+			g.peerErrs.add(peer, req.Keys[0], reqErr)
+		} else {
+			g.split.peerLoaded(elapsed)
+		}
+	}
+	close(done)
+
+	// Wait for every key before raising a panic of any of them.
+	var panicked *singleflight.PanicError
+	for j, i := range batch {
+		r := <-chans[j]
+		if p, ok := r.Err.(*singleflight.PanicError); ok {
+			if panicked == nil {
+				panicked = p
+			}
+			continue
+		}
+		value, err := g.loaded(keys[i], start, joined[j], r.Val, r.Err, r.Dups)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		errs[i] = setSinkView(dests[i], value)
+	}
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+// loadInto loads key into dest, as load does.
+func (g *Group) loadInto(ctx context.Context, key string, dest Sink, failed ProtoGetter) error {
+	value, err := g.load(ctx, key, failed)
 	if err != nil {
 		return err
 	}
-	if destPopulated {
-		return nil
-	}
 	return setSinkView(dest, value)
 }
 
-// load loads key either by invoking the getter locally or by sending it to another machine.
-func (g *Group) load(ctx context.Context, key string, dest Sink) (value ByteView, destPopulated bool, err error) {
+// load loads key either by invoking the getter locally or by sending
+// it to another machine: to the key's owners in order, except failed,
+// an owner which already failed to return it, if not nil.
+//
+// Concurrent loads of key share one call, which goes on while any of
+// their contexts lasts, so the value is loaded into a sink of its own
+// rather than into that of the caller that started it.
+func (g *Group) load(ctx context.Context, key string, failed ProtoGetter) (value ByteView, err error) {
 	g.Stats.Loads.Add(1)
-	viewi, err := g.loadGroup.Do(key, func() (interface{}, error) {
+	start := time.Now()
+	var started atomic.Bool // whether this caller's function runs
+	viewi, err, dups := g.loadGroup.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+		started.Store(true)
 		// Check the cache again because singleflight can only dedup calls
 		// that overlap concurrently.  It's possible for 2 concurrent
 		// requests to miss the cache, resulting in 2 load() calls.  An
@@ -262,43 +761,168 @@ func (g *Group) load(ctx context.Context, key string, dest Sink) (value ByteView
 			g.Stats.CacheHits.Add(1)
 			return value, nil
 		}
+		if err := g.lookupNegative(key); err != nil {
+			return nil, err
+		}
 		g.Stats.LoadsDeduped.Add(1)
-		var value ByteView
-		var err error
-		if peer, ok := g.peers.PickPeer(key); ok {
-			value, err = g.getFromPeer(ctx, peer, key)
-			if err == nil {
-				g.Stats.PeerLoads.Add(1)
-				return value, nil
+		return g.fetch(ctx, key, failed)
+	})
+	return g.loaded(key, start, !started.Load(), viewi, err, dups)
+}
+
+// fetch gets key from its owners in order, except failed, and loads
+// it with the Getter once it is this process's turn, for the function
+// of a load.
+func (g *Group) fetch(ctx context.Context, key string, failed ProtoGetter) (ByteView, error) {
+	var value ByteView
+	var err error
+	owners := g.owners(key)
+	for i, peer := range owners {
+		if peer == nil {
+			break // this process comes before the rest
+		}
+		if peer == failed {
+			continue
+		}
+		value, err = g.getFromPeer(ctx, peer, key)
+		if err == nil {
+			g.Stats.PeerLoads.Add(1)
+			if i > 0 {
+				g.Stats.ReplicaLoads.Add(1)
 			}
+			return value, nil
+		}
+		var peerErr *Error
+		if errors.As(err, &peerErr) {
+			g.Stats.PeerLoads.Add(1)
+		} else {
 			g.Stats.PeerErrors.Add(1)
-			// TODO(bradfitz): log the peer's error? keep
-			// log of the past few for /groupcachez?  It's
-			// probably boring (normal task movement), so not
-			// worth logging I imagine.
+			g.peerErrs.add(peer, key, err)
 		}
-		value, err = g.getLocally(ctx, key, dest)
-		if err != nil {
-			g.Stats.LocalLoadErrs.Add(1)
-			return nil, err
+		if !g.retryLocally(err) {
+			return ByteView{}, err
 		}
-		g.Stats.LocalLoads.Add(1)
-		destPopulated = true // only one caller of load gets this return value
-		g.populateCache(key, value, &g.mainCache)
-		return value, nil
-	})
+	}
+	value, err = g.getLocally(ctx, key, ByteViewSink(&value))
+	if err != nil {
+		g.Stats.LocalLoadErrs.Add(1)
+		g.populateNegative(key, err)
+		return ByteView{}, err
+	}
+	g.Stats.LocalLoads.Add(1)
+	g.populateCache(key, value, &g.mainCache)
+	if len(owners) > 1 {
+		g.fillReplicas(key, value, owners)
+	}
+	return value, nil
+}
+
+// loaded returns the value of a load of key begun at start, given the
+// results of its call and whether the caller joined the call rather
+// than ran its function, counting the load as shared and tracing it.
+func (g *Group) loaded(key string, start time.Time, joined bool, viewi interface{}, err error, dups int) (ByteView, error) {
+	var value ByteView
 	if err == nil {
 		value = viewi.(ByteView)
 	}
-	return
+	if !joined && dups > 0 {
+		g.Stats.LoadsShared.Add(1)
+		g.Stats.LoadWaiters.Add(int64(dups))
+	}
+	if g.opts.TraceLoad != nil {
+		g.opts.TraceLoad(LoadTrace{
+			Key:      key,
+			Joined:   joined,
+			Dups:     dups,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
+	return value, err
+}
+
+// owners returns the owners of key in order of preference, with nil
+// standing for this process.
+func (g *Group) owners(key string) []ProtoGetter {
+	if r, ok := g.peers.(ReplicaPicker); ok {
+		if owners := r.PickPeers(key); len(owners) > 0 {
+			return owners
+		}
+	}
+	if peer, ok := g.peers.PickPeer(key); ok {
+		return []ProtoGetter{peer}
+	}
+	return []ProtoGetter{nil}
+}
+
+// A replicaFill is a value to send to an owner of its key.
+type replicaFill struct {
+	peer  ProtoGetter
+	key   string
+	value ByteView
+}
+
+// fillReplicas sends value, loaded by this process, to the owners of
+// key other than this process, in the background. If too many values
+// are already waiting to be sent, value is dropped.
+func (g *Group) fillReplicas(key string, value ByteView, owners []ProtoGetter) {
+	g.fillOnce.Do(g.startFillers)
+	for _, peer := range owners {
+		if peer == nil {
+			continue
+		}
+		g.Stats.ReplicaFills.Add(1)
+		select {
+		case g.fills <- replicaFill{peer: peer, key: key, value: value}:
+		default:
+			g.Stats.ReplicaFillErrs.Add(1)
+		}
+	}
+}
+
+func (g *Group) startFillers() {
+	g.fills = make(chan replicaFill, replicaFillQueue)
+	for i := 0; i < replicaFillWorkers; i++ {
+		go g.fillWorker()
+	}
+}
+
+// fillWorker sends the values queued by fillReplicas.
+func (g *Group) fillWorker() {
+	for f := range g.fills {
+		ctx, cancel := context.WithTimeout(context.Background(), g.replicaFillTimeout())
+		if err := g.setFromPeer(ctx, f.peer, f.key, f.value); err != nil {
+			g.Stats.ReplicaFillErrs.Add(1)
+		}
+		cancel()
+	}
+}
+
+func (g *Group) replicaFillTimeout() time.Duration {
+	if g.opts.ReplicaFillTimeout > 0 {
+		return g.opts.ReplicaFillTimeout
+	}
+	return defaultReplicaFillTimeout
 }
 
 func (g *Group) getLocally(ctx context.Context, key string, dest Sink) (ByteView, error) {
+	start := time.Now()
 	err := g.getter.Get(ctx, key, dest)
+	elapsed := time.Since(start)
+	g.Latencies.LocalLoads.Observe(elapsed)
+	if err != nil {
+		return ByteView{}, err
+	}
+	g.split.localLoaded(elapsed)
+	value, err := dest.view()
 	if err != nil {
 		return ByteView{}, err
 	}
-	return dest.view()
+	if value.e.IsZero() && g.opts.TTL > 0 {
+		value.e = time.Now().Add(g.opts.TTL)
+		dest.SetExpire(value.e)
+	}
+	return value, nil
 }
 
 func (g *Group) getFromPeer(ctx context.Context, peer ProtoGetter, key string) (ByteView, error) {
@@ -307,20 +931,201 @@ func (g *Group) getFromPeer(ctx context.Context, peer ProtoGetter, key string) (
 		Key:   &key,
 	}
 	res := &pb.GetResponse{}
+	start := time.Now()
 	err := peer.Get(ctx, req, res)
+	elapsed := time.Since(start)
+	g.Latencies.PeerFetches.Observe(elapsed)
 	if err != nil {
 		return ByteView{}, err
 	}
+	g.split.peerLoaded(elapsed)
+	return g.peerValue(key, res)
+}
+
+// peerValue returns the value of key in res, a response of the key's
+// owner.
+func (g *Group) peerValue(key string, res *pb.GetResponse) (ByteView, error) {
+	if res.GetNotFound() {
+		err := &Error{Code: CodeNotFound, Message: ErrNotFound.Error()}
+		if res.ErrorCode != nil {
+			err = &Error{Code: ErrorCode(*res.ErrorCode), Message: res.GetErrorMessage()}
+		}
+		// Remember the owner's negative result until it expires
+		// there.
+		if res.Expire != nil {
+			g.Stats.NegativeLoads.Add(1)
+			g.negCache.add(key, err, time.Unix(0, *res.Expire), g.negativeEntries())
+		}
+		return ByteView{}, err
+	}
+	if res.ErrorCode != nil || res.ErrorMessage != nil {
+		return ByteView{}, &Error{Code: ErrorCode(res.GetErrorCode()), Message: res.GetErrorMessage()}
+	}
 	value := ByteView{b: res.Value}
-	// TODO(bradfitz): use res.MinuteQps or something smart to
-	// conditionally populate hotCache.  For now just do it some
-	// percentage of the time.
-	if rand.Intn(10) == 0 {
-		g.populateCache(key, value, &g.hotCache)
+	if res.Expire != nil {
+		// Expire at the same instant as the owner's copy.
+		value.e = time.Unix(0, *res.Expire)
+	}
+	now := time.Now()
+	if !value.expired(now) {
+		if g.admitHot(key, res.GetMinuteQps(), now) {
+			g.populateCache(key, value, &g.hotCache)
+		} else {
+			g.hotCache.reject()
+		}
 	}
 	return value, nil
 }
 
+// admitHot reports whether a value of key fetched from its owner is
+// to be stored in the hotCache, given the key's rate at the owner.
+func (g *Group) admitHot(key string, ownerQPS float64, now time.Time) bool {
+	return ownerQPS+g.rates.qps(key, now) >= g.hotCacheQPS()
+}
+
+func (g *Group) hotCacheQPS() float64 {
+	if g.opts.HotCacheQPS != 0 {
+		return g.opts.HotCacheQPS
+	}
+	return defaultHotCacheQPS
+}
+
+// SetOptions are the options for Group.Set.
+type SetOptions struct {
+	// Expire specifies when the value expires.
+	// If zero, the group's TTL applies, if any.
+	Expire time.Time
+
+	// HotCache specifies that when another peer owns the key, the
+	// value is also stored in this process's hotCache.
+	HotCache bool
+}
+
+// Set stores value for key in the cache of the key's owner, and in
+// the background in those of its replicas, without calling the
+// Getter. The caller retains ownership of value.
+func (g *Group) Set(ctx context.Context, key string, value []byte, opts *SetOptions) error {
+	g.peersOnce.Do(g.initPeers)
+	var o SetOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Expire.IsZero() && g.opts.TTL > 0 {
+		o.Expire = time.Now().Add(g.opts.TTL)
+	}
+	v := ByteView{b: cloneBytes(value), e: o.Expire}
+	g.negCache.remove(key)
+	owners := g.owners(key)
+	if peer := owners[0]; peer != nil {
+		if err := g.setFromPeer(ctx, peer, key, v); err != nil {
+			return err
+		}
+		if o.HotCache {
+			g.populateCache(key, v, &g.hotCache)
+		} else if g.cacheBytes > 0 {
+			// Don't keep serving an older copy.
+			g.hotCache.remove(key)
+		}
+	} else {
+		g.populateCache(key, v, &g.mainCache)
+	}
+	// Keep the replicas, this process among them, up to date.
+	for _, peer := range owners[1:] {
+		if peer == nil {
+			g.populateCache(key, v, &g.mainCache)
+		}
+	}
+	g.fillReplicas(key, v, owners[1:])
+	return nil
+}
+
+func (g *Group) setFromPeer(ctx context.Context, peer ProtoGetter, key string, value ByteView) error {
+	req := &pb.SetRequest{
+		Group: &g.name,
+		Key:   &key,
+		Value: value.b,
+	}
+	if value.b == nil {
+		req.Value = []byte(value.s)
+	}
+	if !value.e.IsZero() {
+		req.Expire = proto.Int64(value.e.UnixNano())
+	}
+	s, ok := peer.(Setter)
+	if !ok {
+		return errors.New("groupcache: peer does not support Set")
+	}
+	return s.Set(ctx, req)
+}
+
+// Remove removes key from the caches of the whole group: first from
+// the key's owner, then from this process, and then from the hotCache
+// of every other peer. This process's caches are cleared even if the
+// owner fails to remove key. Later Gets of key start a fresh load
+// rather than wait for one in flight, though loads already in flight
+// may repopulate the caches once they complete.
+func (g *Group) Remove(ctx context.Context, key string) error {
+	g.peersOnce.Do(g.initPeers)
+	owner, ok := g.peers.PickPeer(key)
+	var err error
+	if ok {
+		err = g.removeFromPeer(ctx, owner, key)
+	}
+	g.localRemove(key)
+	if err != nil {
+		return err
+	}
+
+	// Drop any hotCache copies held by the other peers.
+	var peers []ProtoGetter
+	if l, ok := g.peers.(PeerLister); ok {
+		peers = l.GetAll()
+	}
+	errs := make(chan error, len(peers))
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		if _, remover := peer.(Remover); !remover || ok && peer == owner {
+			continue
+		}
+		wg.Add(1)
+		go func(peer ProtoGetter) {
+			defer wg.Done()
+			errs <- g.removeFromPeer(ctx, peer, key)
+		}(peer)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Group) removeFromPeer(ctx context.Context, peer ProtoGetter, key string) error {
+	req := &pb.GetRequest{
+		Group: &g.name,
+		Key:   &key,
+	}
+	r, ok := peer.(Remover)
+	if !ok {
+		return errors.New("groupcache: peer does not support Remove")
+	}
+	return r.Remove(ctx, req)
+}
+
+// localRemove removes key from this process's caches only.
+func (g *Group) localRemove(key string) {
+	g.loadGroup.Forget(key)
+	g.negCache.remove(key)
+	if g.cacheBytes <= 0 {
+		return
+	}
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
+}
+
 func (g *Group) lookupCache(key string) (value ByteView, ok bool) {
 	if g.cacheBytes <= 0 {
 		return
@@ -333,25 +1138,72 @@ func (g *Group) lookupCache(key string) (value ByteView, ok bool) {
 	return
 }
 
+// retryLocally reports whether to load a key locally after err from
+// its owner.
+func (g *Group) retryLocally(err error) bool {
+	if g.opts.RetryLocally != nil {
+		return g.opts.RetryLocally(err)
+	}
+	var e *Error
+	return !errors.As(err, &e)
+}
+
+// lookupNegative returns the remembered negative result for key, if
+// any.
+func (g *Group) lookupNegative(key string) error {
+	err, _, ok := g.negCache.get(key)
+	if !ok {
+		return nil
+	}
+	g.Stats.NegativeHits.Add(1)
+	return err
+}
+
+// populateNegative remembers err, returned by the Getter for key, if
+// it's a negative result.
+func (g *Group) populateNegative(key string, err error) {
+	if g.opts.NegativeTTL <= 0 {
+		return
+	}
+	isNegative := g.opts.IsNegative
+	if isNegative == nil {
+		isNegative = func(err error) bool { return errors.Is(err, ErrNotFound) }
+	}
+	if !isNegative(err) {
+		return
+	}
+	g.Stats.NegativeLoads.Add(1)
+	g.negCache.add(key, err, time.Now().Add(g.opts.NegativeTTL), g.negativeEntries())
+}
+
+func (g *Group) negativeEntries() int {
+	if g.opts.NegativeEntries > 0 {
+		return g.opts.NegativeEntries
+	}
+	return defaultNegativeEntries
+}
+
 func (g *Group) populateCache(key string, value ByteView, cache *cache) {
-	if g.cacheBytes <= 0 {
+	now := time.Now()
+	if g.cacheBytes <= 0 || value.expired(now) {
 		return
 	}
 	cache.add(key, value)
 
 	// Evict items from cache(s) if necessary.
+	hotShare := -1.0 // not yet known
 	for {
 		mainBytes := g.mainCache.bytes()
 		hotBytes := g.hotCache.bytes()
 		if mainBytes+hotBytes <= g.cacheBytes {
 			return
 		}
+		if hotShare < 0 {
+			hotShare = g.split.share(g, now)
+		}
 
-		// TODO(bradfitz): this is good-enough-for-now logic.
-		// It should be something based on measurements and/or
-		// respecting the costs of different resources.
 		victim := &g.mainCache
-		if hotBytes > mainBytes/8 {
+		if float64(hotBytes) > hotShare*float64(mainBytes+hotBytes) {
 			victim = &g.hotCache
 		}
 		victim.removeOldest()
@@ -376,106 +1228,428 @@ const (
 func (g *Group) CacheStats(which CacheType) CacheStats {
 	switch which {
 	case MainCache:
-		return g.mainCache.stats()
+		s := g.mainCache.stats()
+		s.Share = 1 - g.split.current()
+		return s
 	case HotCache:
-		return g.hotCache.stats()
+		s := g.hotCache.stats()
+		s.Share = g.split.current()
+		s.AdmitQPS = g.hotCacheQPS()
+		return s
 	default:
 		return CacheStats{}
 	}
 }
 
-// cache is a wrapper around an *lru.Cache that adds synchronization,
-// makes values always be ByteView, and counts the size of all keys and
-// values.
+// cache is a wrapper around EvictionPolicies that adds
+// synchronization, counts the size of all keys and values, and treats
+// expired values as misses. Keys are spread over shards, each with its
+// own lock and policy, so that gets of keys in different shards don't
+// contend.
 type cache struct {
-	mu         sync.RWMutex
-	nbytes     int64 // of all keys and values
-	lru        *lru.Cache
+	eviction Eviction // creates the policies; nil means LRU
+	nshards  int      // number of shards; 0 means GOMAXPROCS
+
+	initOnce sync.Once
+	shards   []cacheShard
+
+	nbytes  atomic.Int64 // of all keys and values
+	nreject atomic.Int64 // number of values not admitted
+}
+
+// A cacheShard holds the keys of a cache which hash to it. Gets are
+// counted per shard, so that they share no memory across shards.
+type cacheShard struct {
+	mu         sync.Mutex
+	policy     EvictionPolicy
+	nbytes     atomic.Int64 // of the shard's keys and values
 	nhit, nget int64
 	nevict     int64 // number of evictions
+
+	_ [64]byte // keeps shards off each other's cache lines
+}
+
+func (c *cache) init() {
+	n := c.nshards
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	eviction := c.eviction
+	if eviction == nil {
+		eviction = LRU
+	}
+	c.shards = make([]cacheShard, n)
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.policy = eviction(func(key string, value ByteView) {
+			size := int64(len(key)) + int64(value.Len())
+			s.nbytes.Add(-size)
+			c.nbytes.Add(-size)
+		})
+	}
+}
+
+// shard returns the shard of key.
+func (c *cache) shard(key string) *cacheShard {
+	c.initOnce.Do(c.init)
+	if len(c.shards) == 1 {
+		return &c.shards[0]
+	}
+	return &c.shards[keyHash(key)%uint32(len(c.shards))]
+}
+
+// keyHash returns the FNV-1a hash of key, computed without converting
+// key to bytes, to spread keys over shards.
+func keyHash(key string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return h
 }
 
 func (c *cache) stats() CacheStats {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return CacheStats{
-		Bytes:     c.nbytes,
-		Items:     c.itemsLocked(),
-		Gets:      c.nget,
-		Hits:      c.nhit,
-		Evictions: c.nevict,
+	c.initOnce.Do(c.init)
+	st := CacheStats{
+		Bytes:   c.nbytes.Load(),
+		Rejects: c.nreject.Load(),
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		st.Items += int64(s.policy.Len())
+		st.Gets += s.nget
+		st.Hits += s.nhit
+		st.Evictions += s.nevict
+		s.mu.Unlock()
 	}
+	return st
 }
 
 func (c *cache) add(key string, value ByteView) {
+	s := c.shard(key)
+	size := int64(len(key)) + int64(value.Len())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Replace any older value in place, keeping what the policy
+	// knows of key, such as how often it is used.
+	if old, ok := s.policy.Peek(key); ok {
+		size -= int64(len(key)) + int64(old.Len())
+	}
+	s.policy.Add(key, value)
+	s.nbytes.Add(size)
+	c.nbytes.Add(size)
+}
+
+func (c *cache) get(key string) (value ByteView, ok bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nget++
+	value, ok = s.policy.Get(key)
+	if !ok {
+		return
+	}
+	if value.expired(time.Now()) {
+		s.policy.Remove(key)
+		return ByteView{}, false
+	}
+	s.nhit++
+	return value, true
+}
+
+func (c *cache) remove(key string) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy.Remove(key)
+}
+
+// removeOldest evicts the oldest entry, as its policy sees it, of the
+// shard holding the most bytes.
+func (c *cache) removeOldest() {
+	c.initOnce.Do(c.init)
+	s := &c.shards[0]
+	for i := 1; i < len(c.shards); i++ {
+		if c.shards[i].nbytes.Load() > s.nbytes.Load() {
+			s = &c.shards[i]
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.policy.Len() > 0 {
+		s.policy.RemoveOldest()
+		s.nevict++
+	}
+}
+
+func (c *cache) reject() {
+	c.nreject.Add(1)
+}
+
+func (c *cache) bytes() int64 {
+	return c.nbytes.Load()
+}
+
+func (c *cache) items() int64 {
+	c.initOnce.Do(c.init)
+	var n int64
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		n += int64(s.policy.Len())
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// negativeCache is a bounded, synchronized set of errors which expire.
+type negativeCache struct {
+	mu  sync.Mutex
+	lru *lru.Cache[string, negativeEntry]
+}
+
+type negativeEntry struct {
+	err    error
+	expire time.Time
+}
+
+func (c *negativeCache) add(key string, err error, expire time.Time, maxEntries int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.lru == nil {
-		c.lru = &lru.Cache{
-			OnEvicted: func(key lru.Key, value interface{}) {
-				val := value.(ByteView)
-				c.nbytes -= int64(len(key.(string))) + int64(val.Len())
-				c.nevict++
-			},
-		}
+		c.lru = lru.NewCache[string, negativeEntry](maxEntries)
 	}
-	c.lru.Add(key, value)
-	c.nbytes += int64(len(key)) + int64(value.Len())
+	c.lru.Add(key, negativeEntry{err: err, expire: expire})
 }
 
-func (c *cache) get(key string) (value ByteView, ok bool) {
+func (c *negativeCache) get(key string) (err error, expire time.Time, ok bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.nget++
 	if c.lru == nil {
 		return
 	}
-	vi, ok := c.lru.Get(key)
+	e, ok := c.lru.Get(key)
 	if !ok {
 		return
 	}
-	c.nhit++
-	return vi.(ByteView), true
+	if !time.Now().Before(e.expire) {
+		c.lru.Remove(key)
+		return nil, time.Time{}, false
+	}
+	return e.err, e.expire, true
 }
 
-func (c *cache) removeOldest() {
+func (c *negativeCache) remove(key string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.lru != nil {
-		c.lru.RemoveOldest()
+		c.lru.Remove(key)
 	}
 }
 
-func (c *cache) bytes() int64 {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.nbytes
+// keyRates tracks the recent request rates of a bounded set of keys.
+// Keys are spread over shards, as in cache, so that requests for keys
+// in different shards don't contend.
+type keyRates struct {
+	shards [rateShards]rateShard
 }
 
-func (c *cache) items() int64 {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.itemsLocked()
+// A rateShard tracks the rates of the keys which hash to it.
+type rateShard struct {
+	mu  sync.Mutex
+	lru *lru.Cache[string, *keyRate]
+
+	_ [64]byte // keeps shards off each other's cache lines
 }
 
-func (c *cache) itemsLocked() int64 {
-	if c.lru == nil {
+const (
+	// rateKeys is the number of most recently requested keys whose
+	// rates are tracked, split evenly between rateShards shards.
+	rateKeys   = 4096
+	rateShards = 16
+)
+
+func (k *keyRates) shard(key string) *rateShard {
+	return &k.shards[keyHash(key)%rateShards]
+}
+
+// keyRate counts the requests for a key in the current and the
+// previous minute.
+type keyRate struct {
+	minute    int64 // current minute, since the Unix epoch
+	cur, prev int64
+}
+
+// advance moves r's window to the minute of now.
+func (r *keyRate) advance(now time.Time) {
+	m := now.Unix() / 60
+	switch m - r.minute {
+	case 0:
+		return
+	case 1:
+		r.prev, r.cur = r.cur, 0
+	default:
+		r.prev, r.cur = 0, 0
+	}
+	r.minute = m
+}
+
+func (k *keyRates) record(key string, now time.Time) {
+	s := k.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lru == nil {
+		s.lru = lru.NewCache[string, *keyRate](rateKeys / rateShards)
+	}
+	r, ok := s.lru.Get(key)
+	if !ok {
+		r = &keyRate{minute: now.Unix() / 60}
+		s.lru.Add(key, r)
+	}
+	r.advance(now)
+	r.cur++
+}
+
+// qps returns the rate of requests for key over the last minute, in
+// requests per second. The requests of the previous minute are
+// weighted by how much of it is still within the last minute.
+func (k *keyRates) qps(key string, now time.Time) float64 {
+	s := k.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lru == nil {
+		return 0
+	}
+	r, ok := s.lru.Get(key)
+	if !ok {
 		return 0
 	}
-	return int64(c.lru.Len())
+	r.advance(now)
+	return r.qps(now)
+}
+
+// qps returns the rate of r over the minute up to now, once r has
+// advanced to now.
+func (r *keyRate) qps(now time.Time) float64 {
+	elapsed := float64(now.Unix()%60) + float64(now.Nanosecond())/1e9
+	return (float64(r.prev)*(1-elapsed/60) + float64(r.cur)) / 60
+}
+
+// A KeyQPS is the recent request rate of a key.
+type KeyQPS struct {
+	Key string
+	QPS float64 // over the last minute; see GroupOptions.HotCacheQPS
+}
+
+// TopKeys returns up to n of the keys with the highest request rates
+// in this process over the last minute, highest first. Only the rates
+// of the most recently requested keys are tracked.
+func (g *Group) TopKeys(n int) []KeyQPS {
+	return g.rates.top(n, time.Now())
+}
+
+func (k *keyRates) top(n int, now time.Time) []KeyQPS {
+	// Copy the rates, so that the shards are locked only while
+	// copying them and not while computing and sorting.
+	type keyRateCopy struct {
+		key string
+		r   keyRate
+	}
+	var rates []keyRateCopy
+	for i := range k.shards {
+		s := &k.shards[i]
+		s.mu.Lock()
+		if s.lru != nil {
+			for _, key := range s.lru.Keys() {
+				r, _ := s.lru.Peek(key)
+				rates = append(rates, keyRateCopy{key, *r})
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	var res []KeyQPS
+	for i := range rates {
+		r := &rates[i].r
+		r.advance(now)
+		if qps := r.qps(now); qps > 0 {
+			res = append(res, KeyQPS{Key: rates[i].key, QPS: qps})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].QPS != res[j].QPS {
+			return res[i].QPS > res[j].QPS
+		}
+		return res[i].Key < res[j].Key
+	})
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// A PeerError is a transport error talking to a peer.
+type PeerError struct {
+	Time time.Time
+	Peer string // the peer's address, if its ProtoGetter is a fmt.Stringer
+	Key  string
+	Err  string
+}
+
+// RecentPeerErrors returns the last few transport errors of the group
+// talking to peers, newest first. They are probably boring, being
+// mostly due to normal task movement, but help to debug the peers.
+func (g *Group) RecentPeerErrors() []PeerError {
+	return g.peerErrs.recent()
+}
+
+// peerErrorLogSize is the number of peer errors a group keeps.
+const peerErrorLogSize = 16
+
+// peerErrorLog is a ring of the last peerErrorLogSize peer errors.
+type peerErrorLog struct {
+	mu   sync.Mutex
+	errs [peerErrorLogSize]PeerError
+	n    int // errors ever added
+}
+
+func (l *peerErrorLog) add(peer ProtoGetter, key string, err error) {
+	e := PeerError{Time: time.Now(), Key: key, Err: err.Error()}
+	if s, ok := peer.(fmt.Stringer); ok {
+		e.Peer = s.String()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs[l.n%peerErrorLogSize] = e
+	l.n++
+}
+
+func (l *peerErrorLog) recent() []PeerError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var res []PeerError
+	for i := l.n - 1; i >= 0 && i >= l.n-peerErrorLogSize; i-- {
+		res = append(res, l.errs[i%peerErrorLogSize])
+	}
+	return res
 }
 
-// An AtomicInt is an int64 to be accessed atomically.
-type AtomicInt int64
+// An AtomicInt is an int64 to be accessed atomically. It is 8-byte
+// aligned wherever it is, even on 32-bit platforms.
+type AtomicInt struct {
+	v atomic.Int64
+}
 
 // Add atomically adds n to i.
 func (i *AtomicInt) Add(n int64) {
-	atomic.AddInt64((*int64)(i), n)
+	i.v.Add(n)
 }
 
 // Get atomically gets the value of i.
 func (i *AtomicInt) Get() int64 {
-	return atomic.LoadInt64((*int64)(i))
+	return i.v.Load()
 }
 
 func (i *AtomicInt) String() string {
@@ -489,4 +1663,7 @@ type CacheStats struct {
 	Gets      int64
 	Hits      int64
 	Evictions int64
+	Rejects   int64   // values not admitted into the hotCache
+	AdmitQPS  float64 // hotCache admission threshold; see GroupOptions.HotCacheQPS
+	Share     float64 // share of the group's cache bytes; see GroupOptions.CacheSplit
 }
@@ -25,15 +25,17 @@ import (
 	"hash/crc32"
 	"math/rand"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
-	"unsafe"
 
 	"github.com/golang/protobuf/proto"
 
-	pb "github.com/golang/groupcache/groupcachepb"
-	testpb "github.com/golang/groupcache/testpb"
+	pb "groupcache/groupcachepb"
+	"groupcache/singleflight"
+	testpb "groupcache/testpb"
 )
 
 var (
@@ -59,13 +61,15 @@ const (
 )
 
 func testSetup() {
-	stringGroup = NewGroup(stringGroupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+	// One cache shard, for TestCacheEviction to evict in exact LRU
+	// order.
+	stringGroup = NewGroupOpts(stringGroupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
 		if key == fromChan {
 			key = <-stringc
 		}
 		cacheFills.Add(1)
 		return dest.SetString("ECHO:" + key)
-	}))
+	}), &GroupOptions{CacheShards: 1})
 
 	protoGroup = NewGroup(protoGroupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
 		if key == fromChan {
@@ -203,7 +207,7 @@ func TestCacheEviction(t *testing.T) {
 	}
 
 	g := stringGroup.(*Group)
-	evict0 := g.mainCache.nevict
+	evict0 := g.mainCache.stats().Evictions
 
 	// Trash the cache with other keys.
 	var bytesFlooded int64
@@ -214,7 +218,7 @@ func TestCacheEviction(t *testing.T) {
 		stringGroup.Get(dummyCtx, key, StringSink(&res))
 		bytesFlooded += int64(len(key) + len(res))
 	}
-	evicts := g.mainCache.nevict - evict0
+	evicts := g.mainCache.stats().Evictions - evict0
 	if evicts <= 0 {
 		t.Errorf("evicts = %v; want more than 0", evicts)
 	}
@@ -226,9 +230,221 @@ func TestCacheEviction(t *testing.T) {
 	}
 }
 
+// TestCacheEvictionPolicies tests that each eviction policy evicts
+// keys, and that all but LRU keep a popular key through a scan.
+func TestCacheEvictionPolicies(t *testing.T) {
+	policies := []struct {
+		name          string
+		eviction      Eviction
+		scanResistant bool
+	}{
+		{"LRU", LRU, false},
+		{"LFU", LFU, true},
+		{"TwoQueue", TwoQueue, true},
+		{"TinyLFU", TinyLFU, true},
+	}
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			var fills int
+			g := newGroupOpts("TestCacheEvictionPolicies-"+p.name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+				fills++
+				return dest.SetString("ECHO:" + key)
+			}), nil, &GroupOptions{Eviction: p.eviction, CacheShards: 1})
+			testKey := "TestCacheEvictionPolicies-key"
+			getTestKey := func() {
+				var res string
+				for i := 0; i < 10; i++ {
+					if err := g.Get(dummyCtx, testKey, StringSink(&res)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			getTestKey()
+			if fills != 1 {
+				t.Fatalf("expected 1 cache fill; got %d", fills)
+			}
+
+			// Trash the cache with other keys, each used once.
+			var bytesFlooded int64
+			for bytesFlooded < cacheSize+1024 {
+				var res string
+				key := fmt.Sprintf("dummy-key-%d", bytesFlooded)
+				g.Get(dummyCtx, key, StringSink(&res))
+				bytesFlooded += int64(len(key) + len(res))
+			}
+			if evicts := g.mainCache.stats().Evictions; evicts <= 0 {
+				t.Errorf("evicts = %v; want more than 0", evicts)
+			}
+			if bytes := g.mainCache.bytes(); bytes > cacheSize {
+				t.Errorf("cache holds %d bytes; want at most %d", bytes, cacheSize)
+			}
+
+			fills = 0
+			getTestKey()
+			if kept := fills == 0; kept != p.scanResistant {
+				t.Errorf("key kept through scan = %v; want %v", kept, p.scanResistant)
+			}
+		})
+	}
+}
+
+// TestCacheReplace tests that replacing the value of a key keeps what
+// the eviction policy knows of the key, and the cache's size right.
+func TestCacheReplace(t *testing.T) {
+	for _, p := range []struct {
+		name     string
+		eviction Eviction
+	}{
+		{"LFU", LFU},
+		{"TwoQueue", TwoQueue},
+	} {
+		c := &cache{eviction: p.eviction, nshards: 1}
+		c.add("hot", ByteView{s: "old"})
+		for i := 0; i < 3; i++ {
+			c.get("hot")
+		}
+		c.add("hot", ByteView{s: "newer"})
+		if want := int64(len("hot") + len("newer")); c.bytes() != want {
+			t.Errorf("%s: cache holds %d bytes after replacing; want %d", p.name, c.bytes(), want)
+		}
+
+		// Cold keys, each used once, go before the hot key.
+		for i := 0; i < 100; i++ {
+			c.add(fmt.Sprintf("cold-%d", i), ByteView{s: "x"})
+			for c.items() > 10 {
+				c.removeOldest()
+			}
+		}
+		if v, ok := c.get("hot"); !ok || v.String() != "newer" {
+			t.Errorf("%s: hot key = %q, %v after a scan; want it kept", p.name, v, ok)
+		}
+	}
+}
+
+// TestCacheShards tests that a sharded cache spreads keys over its
+// shards and keeps within the group's cache bytes.
+func TestCacheShards(t *testing.T) {
+	const shards = 8
+	g := newGroupOpts("TestCacheShards", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("ECHO:" + key)
+	}), nil, &GroupOptions{CacheShards: shards})
+	var bytesFlooded int64
+	for bytesFlooded < 2*cacheSize {
+		var res string
+		key := fmt.Sprintf("dummy-key-%d", bytesFlooded)
+		if err := g.Get(dummyCtx, key, StringSink(&res)); err != nil {
+			t.Fatal(err)
+		}
+		bytesFlooded += int64(len(key) + len(res))
+	}
+	if n := len(g.mainCache.shards); n != shards {
+		t.Fatalf("mainCache has %d shards; want %d", n, shards)
+	}
+	var sum int64
+	for i := range g.mainCache.shards {
+		s := &g.mainCache.shards[i]
+		if s.policy.Len() == 0 {
+			t.Errorf("shard %d is empty", i)
+		}
+		sum += s.nbytes.Load()
+	}
+	if bytes := g.mainCache.bytes(); bytes != sum || bytes > cacheSize {
+		t.Errorf("cache holds %d bytes, shards %d; want equal and at most %d", bytes, sum, cacheSize)
+	}
+	if s := g.CacheStats(MainCache); s.Evictions <= 0 {
+		t.Errorf("evictions = %d; want more than 0", s.Evictions)
+	}
+
+	key := "dummy-key-0"
+	g.mainCache.add(key, ByteView{s: "x"})
+	g.Remove(dummyCtx, key)
+	if _, ok := g.mainCache.get(key); ok {
+		t.Errorf("key %q still cached after Remove", key)
+	}
+}
+
+func TestKeyRatesTop(t *testing.T) {
+	var k keyRates
+	now := time.Unix(6000, 0)
+	for key, n := range map[string]int{"a": 3, "b": 1, "c": 3, "d": 2} {
+		for i := 0; i < n; i++ {
+			k.record(key, now)
+		}
+	}
+	got := k.top(3, now)
+	want := []KeyQPS{{"a", 3.0 / 60}, {"c", 3.0 / 60}, {"d", 2.0 / 60}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("top(3) = %v; want %v", got, want)
+	}
+	// Rates from two minutes before are gone.
+	if got := k.top(3, now.Add(2*time.Minute)); len(got) != 0 {
+		t.Errorf("top(3) two minutes later = %v; want none", got)
+	}
+	if qps := k.qps("a", now); qps != 3.0/60 {
+		t.Errorf("qps(a) = %v after top; want %v", qps, 3.0/60)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	var h Histogram
+	for _, d := range []time.Duration{0, latencyBuckets[0], latencyBuckets[0] + 1, time.Hour} {
+		h.Observe(d)
+	}
+	s := h.Snapshot()
+	want := make([]int64, len(latencyBuckets)+1)
+	want[0], want[1], want[len(latencyBuckets)] = 2, 1, 1
+	if !reflect.DeepEqual(s.Counts, want) {
+		t.Errorf("counts = %v; want %v", s.Counts, want)
+	}
+	if wantSum := 2*latencyBuckets[0] + 1 + time.Hour; s.Count != 4 || s.Sum != wantSum {
+		t.Errorf("count, sum = %d, %v; want 4, %v", s.Count, s.Sum, wantSum)
+	}
+
+	// The buckets can't be changed from outside.
+	LatencyBuckets()[0] = time.Hour
+	if latencyBuckets[0] == time.Hour {
+		t.Error("changing the result of LatencyBuckets changed the buckets")
+	}
+}
+
+// TestLatencies tests that a group times its local loads and peer
+// fetches, and that its workspace lists it.
+func TestLatencies(t *testing.T) {
+	ws := NewWorkspace()
+	peer := &fakePeer{}
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	})
+	g := ws.newGroupOpts("b", cacheSize, getter, fakePeers{nil}, nil)
+	remote := ws.newGroupOpts("a", cacheSize, getter, fakePeers{peer}, nil)
+	var s string
+	if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if n := g.Latencies.LocalLoads.Snapshot().Count; n != 1 {
+		t.Errorf("local loads timed = %d; want 1", n)
+	}
+	if n := remote.Latencies.PeerFetches.Snapshot().Count; n != 1 {
+		t.Errorf("peer fetches timed = %d; want 1", n)
+	}
+	if groups := ws.Groups(); len(groups) != 2 || groups[0] != remote || groups[1] != g {
+		t.Errorf("Groups() = %v; want [a b]", groups)
+	}
+}
+
 type fakePeer struct {
-	hits int
-	fail bool
+	hits     int
+	removes  int
+	mu       sync.Mutex // guards sets, which may come in the background
+	sets     map[string][]byte
+	fail     bool
+	notFound bool
+	expire   time.Time
+	err      *Error // returned as the owner's Getter error
+	qps      float64
 }
 
 func (p *fakePeer) Get(_ context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
@@ -236,7 +452,65 @@ func (p *fakePeer) Get(_ context.Context, in *pb.GetRequest, out *pb.GetResponse
 	if p.fail {
 		return errors.New("simulated error from peer")
 	}
+	if p.err != nil {
+		out.ErrorCode = proto.Int32(int32(p.err.Code))
+		out.ErrorMessage = proto.String(p.err.Message)
+		return nil
+	}
+	if p.notFound {
+		out.NotFound = proto.Bool(true)
+		out.Expire = proto.Int64(p.expire.UnixNano())
+		return nil
+	}
 	out.Value = []byte("got:" + in.GetKey())
+	if p.qps != 0 {
+		out.MinuteQps = proto.Float64(p.qps)
+	}
+	if !p.expire.IsZero() {
+		out.Expire = proto.Int64(p.expire.UnixNano())
+	}
+	return nil
+}
+
+func (p *fakePeer) Remove(_ context.Context, in *pb.GetRequest) error {
+	p.removes++
+	if p.fail {
+		return errors.New("simulated error from peer")
+	}
+	return nil
+}
+
+func (p *fakePeer) Set(_ context.Context, in *pb.SetRequest) error {
+	if p.fail {
+		return errors.New("simulated error from peer")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sets == nil {
+		p.sets = make(map[string][]byte)
+	}
+	p.sets[in.GetKey()] = in.GetValue()
+	return nil
+}
+
+// fakeMultiPeer is a fakePeer which gets several keys in one request.
+type fakeMultiPeer struct {
+	fakePeer
+	batches int
+}
+
+func (p *fakeMultiPeer) GetMulti(ctx context.Context, in *pb.GetMultiRequest, out *pb.GetMultiResponse) error {
+	p.mu.Lock() // batches may come concurrently
+	defer p.mu.Unlock()
+	p.batches++
+	if p.fail {
+		return errors.New("simulated error from peer")
+	}
+	for _, key := range in.Keys {
+		res := &pb.GetResponse{}
+		p.Get(ctx, &pb.GetRequest{Group: in.Group, Key: proto.String(key)}, res)
+		out.Responses = append(out.Responses, res)
+	}
 	return nil
 }
 
@@ -250,6 +524,46 @@ func (p fakePeers) PickPeer(key string) (peer ProtoGetter, ok bool) {
 	return p[n], p[n] != nil
 }
 
+func (p fakePeers) GetAll() []ProtoGetter {
+	var peers []ProtoGetter
+	for _, peer := range p {
+		if peer != nil {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// waitSet waits for p to be sent a value for key, and returns it.
+func (p *fakePeer) waitSet(key string) (string, bool) {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		p.mu.Lock()
+		v, ok := p.sets[key]
+		p.mu.Unlock()
+		if ok {
+			return string(v), true
+		}
+	}
+	return "", false
+}
+
+// fakeReplicas is a ReplicaPicker giving every key the same owners.
+type fakeReplicas struct {
+	owners []ProtoGetter
+}
+
+func (p *fakeReplicas) PickPeer(key string) (ProtoGetter, bool) {
+	return p.owners[0], p.owners[0] != nil
+}
+
+func (p *fakeReplicas) GetAll() []ProtoGetter {
+	return fakePeers(p.owners).GetAll()
+}
+
+func (p *fakeReplicas) PickPeers(key string) []ProtoGetter {
+	return p.owners
+}
+
 // TestPeers tests that peers (virtual, in-process) are hit, and how much.
 func TestPeers(t *testing.T) {
 	once.Do(testSetup)
@@ -303,9 +617,9 @@ func TestPeers(t *testing.T) {
 	resetCacheSize(1 << 20)
 	run("base", 200, "localHits = 49, peers = 51 49 51")
 
-	// Verify cache was hit.  All localHits are gone, and some of
-	// the peer hits (the ones randomly selected to be maybe hot)
-	run("cached_base", 200, "localHits = 0, peers = 49 47 48")
+	// Verify cache was hit.  All localHits are gone, and the peer
+	// hits remain, since no key is requested often enough to be hot.
+	run("cached_base", 200, "localHits = 0, peers = 51 49 51")
 	resetCacheSize(0)
 
 	// With one of the peers being down.
@@ -322,6 +636,1143 @@ func TestPeers(t *testing.T) {
 	run("peer0_failing", 200, "localHits = 100, peers = 51 49 51")
 }
 
+func TestSinkExpire(t *testing.T) {
+	var fills int
+	g := newGroup("TestSinkExpire-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills++
+		if key == "expired" {
+			dest.SetExpire(time.Now().Add(-time.Second))
+		} else {
+			dest.SetExpire(time.Now().Add(time.Hour))
+		}
+		return dest.SetString("ECHO:" + key)
+	}), nil)
+	for _, key := range []string{"fresh", "expired"} {
+		fills = 0
+		for i := 0; i < 3; i++ {
+			var s string
+			if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want := 1
+		if key == "expired" {
+			want = 3
+		}
+		if fills != want {
+			t.Errorf("%s: got %d fills; want %d", key, fills, want)
+		}
+	}
+}
+
+func TestGroupTTL(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	var fills int
+	g := newGroupOpts("TestGroupTTL-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills++
+		return dest.SetString("ECHO:" + key)
+	}), nil, &GroupOptions{TTL: ttl})
+
+	var v ByteView
+	if err := g.Get(dummyCtx, "key", ByteViewSink(&v)); err != nil {
+		t.Fatal(err)
+	}
+	if v.Expire().IsZero() {
+		t.Error("value loaded with a group TTL has no expiry")
+	}
+	if err := g.Get(dummyCtx, "key", ByteViewSink(&v)); err != nil {
+		t.Fatal(err)
+	}
+	if fills != 1 {
+		t.Errorf("before TTL: got %d fills; want 1", fills)
+	}
+	time.Sleep(2 * ttl)
+	if err := g.Get(dummyCtx, "key", ByteViewSink(&v)); err != nil {
+		t.Fatal(err)
+	}
+	if fills != 2 {
+		t.Errorf("after TTL: got %d fills; want 2", fills)
+	}
+	if n := g.mainCache.items(); n != 1 {
+		t.Errorf("mainCache has %d items; want 1", n)
+	}
+}
+
+func TestPeerExpire(t *testing.T) {
+	expire := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	peer := &fakePeer{expire: expire}
+	g := newGroup("TestPeerExpire-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return errors.New("unexpected local load")
+	}), fakePeers{peer})
+	g.initPeers()
+	value, err := g.getFromPeer(dummyCtx, peer, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !value.Expire().Equal(expire) {
+		t.Errorf("value expires at %v; want %v", value.Expire(), expire)
+	}
+
+	// An entry the owner already expired is a miss in the hotCache.
+	g.hotCache.add("stale", ByteView{s: "stale", e: time.Now().Add(-time.Second)})
+	if _, ok := g.lookupCache("stale"); ok {
+		t.Error("lookupCache returned an expired value")
+	}
+	if n := g.hotCache.items(); n != 0 {
+		t.Errorf("hotCache has %d items after expiry; want 0", n)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	peer0 := &fakePeer{}
+	peer1 := &fakePeer{}
+	peerList := fakePeers([]ProtoGetter{peer0, peer1, nil})
+	var fills int
+	g := newGroup("TestRemove-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills++
+		return dest.SetString("got:" + key)
+	}), peerList)
+
+	// Find a key owned by this process and one owned by a peer.
+	var localKey, peerKey string
+	for i := 0; localKey == "" || peerKey == ""; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if _, ok := peerList.PickPeer(key); ok {
+			peerKey = key
+		} else {
+			localKey = key
+		}
+	}
+
+	var s string
+	if err := g.Get(dummyCtx, localKey, StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	g.populateCache(peerKey, ByteView{s: "got:" + peerKey}, &g.hotCache)
+
+	if err := g.Remove(dummyCtx, localKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Remove(dummyCtx, peerKey); err != nil {
+		t.Fatal(err)
+	}
+	if n := g.mainCache.items() + g.hotCache.items(); n != 0 {
+		t.Errorf("caches hold %d items after Remove; want 0", n)
+	}
+	if g.mainCache.bytes()+g.hotCache.bytes() != 0 {
+		t.Errorf("caches hold %d bytes after Remove; want 0", g.mainCache.bytes()+g.hotCache.bytes())
+	}
+	// Each peer sees one removal per key: either as the owner or
+	// to drop its hotCache copy.
+	if peer0.removes != 2 || peer1.removes != 2 {
+		t.Errorf("peer removes = %d %d; want 2 2", peer0.removes, peer1.removes)
+	}
+
+	if err := g.Get(dummyCtx, localKey, StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if fills != 2 {
+		t.Errorf("got %d fills; want 2 after Remove", fills)
+	}
+
+	// A failing owner fails the Remove, which still drops the local
+	// copy.
+	g.populateCache(peerKey, ByteView{s: "got:" + peerKey}, &g.hotCache)
+	peer0.fail, peer1.fail = true, true
+	if err := g.Remove(dummyCtx, peerKey); err == nil {
+		t.Error("Remove with failing owner succeeded")
+	}
+	if _, ok := g.hotCache.get(peerKey); ok {
+		t.Error("hotCache kept its copy after a failed Remove")
+	}
+}
+
+// getOnlyPeer is a peer implementing only ProtoGetter.
+type getOnlyPeer struct{ peer fakePeer }
+
+func (p *getOnlyPeer) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	return p.peer.Get(ctx, in, out)
+}
+
+// onlyPicker is a PeerPicker which is not a PeerLister.
+type onlyPicker struct{ peer ProtoGetter }
+
+func (p onlyPicker) PickPeer(key string) (ProtoGetter, bool) { return p.peer, true }
+
+// TestOptionalPeerMethods tests that peers and pickers without the
+// optional Remover, Setter and PeerLister methods still work for
+// Gets, and fail the operations they can't do.
+func TestOptionalPeerMethods(t *testing.T) {
+	peer := &getOnlyPeer{}
+	g := newGroup("TestOptionalPeerMethods-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local")
+	}), onlyPicker{peer})
+	var s string
+	if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil || s != "got:key" {
+		t.Errorf("Get = %q, %v; want got:key from the peer", s, err)
+	}
+	if err := g.Set(dummyCtx, "key", []byte("v"), nil); err == nil {
+		t.Error("Set to a peer without Set succeeded")
+	}
+	if err := g.Remove(dummyCtx, "key"); err == nil {
+		t.Error("Remove from a peer without Remove succeeded")
+	}
+}
+
+func TestSet(t *testing.T) {
+	peer := &fakePeer{}
+	peerList := fakePeers([]ProtoGetter{peer, nil})
+	var fills int
+	g := newGroup("TestSet-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills++
+		return dest.SetString("got:" + key)
+	}), peerList)
+
+	var localKey, peerKey string
+	for i := 0; localKey == "" || peerKey == ""; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if _, ok := peerList.PickPeer(key); ok {
+			peerKey = key
+		} else {
+			localKey = key
+		}
+	}
+
+	// A locally owned key is stored in the mainCache and replaces
+	// any older value.
+	var s string
+	if err := g.Get(dummyCtx, localKey, StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Set(dummyCtx, localKey, []byte("set"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Get(dummyCtx, localKey, StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if s != "set" || fills != 1 {
+		t.Errorf("Get after Set = %q with %d fills; want %q with 1 fill", s, fills, "set")
+	}
+	if want := int64(len(localKey) + len("set")); g.mainCache.bytes() != want {
+		t.Errorf("mainCache has %d bytes; want %d", g.mainCache.bytes(), want)
+	}
+
+	// A key owned by a peer is sent to the peer, and only kept in
+	// the hotCache when asked to.
+	if err := g.Set(dummyCtx, peerKey, []byte("remote"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(peer.sets[peerKey]); got != "remote" {
+		t.Errorf("peer got %q; want %q", got, "remote")
+	}
+	if _, ok := g.hotCache.get(peerKey); ok {
+		t.Error("Set without HotCache populated the hotCache")
+	}
+	expire := time.Now().Add(time.Hour)
+	if err := g.Set(dummyCtx, peerKey, []byte("hot"), &SetOptions{Expire: expire, HotCache: true}); err != nil {
+		t.Fatal(err)
+	}
+	v, ok := g.hotCache.get(peerKey)
+	if !ok || v.String() != "hot" || !v.Expire().Equal(expire) {
+		t.Errorf("hotCache has %q (expire %v, ok %v); want %q (expire %v)", v, v.Expire(), ok, "hot", expire)
+	}
+
+	peer.fail = true
+	if err := g.Set(dummyCtx, peerKey, []byte("fail"), nil); err == nil {
+		t.Error("Set with failing owner succeeded")
+	}
+}
+
+func TestWorkspaces(t *testing.T) {
+	newWorkspaceGroup := func(prefix string) (*Workspace, *Group) {
+		ws := NewWorkspace()
+		g := ws.NewGroup("TestWorkspaces-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			return dest.SetString(prefix + key)
+		}))
+		return ws, g
+	}
+	// The same group name in two workspaces doesn't collide.
+	ws1, g1 := newWorkspaceGroup("ws1:")
+	ws2, g2 := newWorkspaceGroup("ws2:")
+	if ws1.GetGroup(g1.Name()) != g1 || ws2.GetGroup(g2.Name()) != g2 {
+		t.Fatal("GetGroup returned a group from the wrong workspace")
+	}
+	if GetGroup(g1.Name()) != nil {
+		t.Error("group created in a workspace is visible in DefaultWorkspace")
+	}
+	for g, want := range map[*Group]string{g1: "ws1:key", g2: "ws2:key"} {
+		var s string
+		if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		if s != want {
+			t.Errorf("Get = %q; want %q", s, want)
+		}
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	const negativeTTL = 50 * time.Millisecond
+	errBroken := errors.New("broken")
+	fills := make(map[string]int)
+	g := newGroupOpts("TestNegativeCache-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills[key]++
+		switch key {
+		case "missing":
+			return fmt.Errorf("no row for %q: %w", key, ErrNotFound)
+		case "broken":
+			return errBroken
+		}
+		return dest.SetString("ECHO:" + key)
+	}), nil, &GroupOptions{NegativeTTL: negativeTTL})
+
+	get := func(key string) error {
+		var s string
+		return g.Get(dummyCtx, key, StringSink(&s))
+	}
+	for i := 0; i < 3; i++ {
+		if err := get("missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get(missing) = %v; want ErrNotFound", err)
+		}
+		if err := get("broken"); err != errBroken {
+			t.Fatalf("Get(broken) = %v; want %v", err, errBroken)
+		}
+	}
+	if fills["missing"] != 1 || fills["broken"] != 3 {
+		t.Errorf("fills = %v; want 1 for missing, 3 for broken", fills)
+	}
+	if hits := g.Stats.NegativeHits.Get(); hits != 2 {
+		t.Errorf("NegativeHits = %d; want 2", hits)
+	}
+	if loads := g.Stats.NegativeLoads.Get(); loads != 1 {
+		t.Errorf("NegativeLoads = %d; want 1", loads)
+	}
+
+	// Negative results expire, and are dropped by Remove and Set.
+	time.Sleep(2 * negativeTTL)
+	get("missing")
+	if fills["missing"] != 2 {
+		t.Errorf("after NegativeTTL: got %d fills; want 2", fills["missing"])
+	}
+	g.Remove(dummyCtx, "missing")
+	get("missing")
+	if fills["missing"] != 3 {
+		t.Errorf("after Remove: got %d fills; want 3", fills["missing"])
+	}
+	g.Set(dummyCtx, "missing", []byte("found"), nil)
+	if err := get("missing"); err != nil {
+		t.Errorf("Get after Set = %v; want nil", err)
+	}
+}
+
+func TestNegativeCachePolicy(t *testing.T) {
+	errGone := errors.New("gone")
+	var fills int
+	g := newGroupOpts("TestNegativeCachePolicy-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills++
+		if key == "gone" {
+			return errGone
+		}
+		return ErrNotFound
+	}), nil, &GroupOptions{
+		NegativeTTL: time.Hour,
+		IsNegative:  func(err error) bool { return err == errGone },
+	})
+	for _, key := range []string{"gone", "gone", "missing", "missing"} {
+		var s string
+		g.Get(dummyCtx, key, StringSink(&s))
+	}
+	if fills != 3 {
+		t.Errorf("got %d fills; want 3", fills)
+	}
+}
+
+func TestPeerNegative(t *testing.T) {
+	peer := &fakePeer{notFound: true, expire: time.Now().Add(time.Hour)}
+	var localHits int
+	g := newGroup("TestPeerNegative-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		localHits++
+		return dest.SetString("local")
+	}), fakePeers{peer})
+	for i := 0; i < 2; i++ {
+		var s string
+		if err := g.Get(dummyCtx, "key", StringSink(&s)); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get = %v; want ErrNotFound", err)
+		}
+	}
+	if localHits != 0 {
+		t.Errorf("negative result from peer fell back to %d local loads", localHits)
+	}
+	if peer.hits != 1 {
+		t.Errorf("peer hit %d times; want 1", peer.hits)
+	}
+}
+
+func TestPeerError(t *testing.T) {
+	const codeDenied ErrorCode = 7
+	peer := &fakePeer{err: &Error{Code: codeDenied, Message: "denied"}}
+	var localHits int
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		localHits++
+		return dest.SetString("local")
+	})
+	g := newGroup("TestPeerError-group", cacheSize, getter, fakePeers{peer})
+	var s string
+	err := g.Get(dummyCtx, "key", StringSink(&s))
+	var e *Error
+	if !errors.As(err, &e) || e.Code != codeDenied || e.Message != "denied" {
+		t.Fatalf("Get = %#v; want *Error with code %d", err, codeDenied)
+	}
+	if localHits != 0 {
+		t.Errorf("error from peer's Getter fell back to %d local loads", localHits)
+	}
+	if n := g.Stats.PeerErrors.Get(); n != 0 {
+		t.Errorf("PeerErrors = %d; want 0", n)
+	}
+
+	// Transport errors are retried locally.
+	peer.err, peer.fail = nil, true
+	if err := g.Get(dummyCtx, "key2", StringSink(&s)); err != nil || s != "local" {
+		t.Errorf("Get after transport error = %q, %v; want local value", s, err)
+	}
+	if n := g.Stats.PeerErrors.Get(); n != 1 {
+		t.Errorf("PeerErrors = %d; want 1", n)
+	}
+
+	// RetryLocally overrides the policy.
+	peer.err, peer.fail = &Error{Code: codeDenied, Message: "denied"}, false
+	g = newGroupOpts("TestPeerError-retry-group", cacheSize, getter, fakePeers{peer}, &GroupOptions{
+		RetryLocally: func(err error) bool { return errorCode(err) == codeDenied },
+	})
+	if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil || s != "local" {
+		t.Errorf("Get with RetryLocally = %q, %v; want local value", s, err)
+	}
+}
+
+func TestHotCacheAdmission(t *testing.T) {
+	peer := &fakePeer{qps: 5}
+	g := newGroupOpts("TestHotCacheAdmission-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local")
+	}), fakePeers{peer}, &GroupOptions{HotCacheQPS: 10})
+	get := func(key string) {
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Keys not hot enough at the owner are not admitted.
+	get("cold")
+	if _, ok := g.hotCache.get("cold"); ok {
+		t.Error("key at 5 qps admitted into hotCache")
+	}
+	if s := g.CacheStats(HotCache); s.Rejects != 1 || s.AdmitQPS != 10 {
+		t.Errorf("hotCache Rejects = %d, AdmitQPS = %v; want 1, 10", s.Rejects, s.AdmitQPS)
+	}
+
+	peer.qps = 20
+	get("hot")
+	if _, ok := g.hotCache.get("hot"); !ok {
+		t.Error("key at 20 qps not admitted into hotCache")
+	}
+
+	// Keys requested often enough in this process are admitted too.
+	peer.qps, peer.hits = 0, 0
+	for i := 0; i < 1000; i++ {
+		get("local-hot")
+	}
+	if peer.hits == 0 || peer.hits >= 1000 {
+		t.Errorf("peer hit %d times for 1000 Gets of a locally hot key; want a few hundred at most", peer.hits)
+	}
+}
+
+func TestMinuteQps(t *testing.T) {
+	g := newGroup("TestMinuteQps-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("ECHO:" + key)
+	}), nil)
+	var res *pb.GetResponse
+	for i := 0; i < 120; i++ {
+		var err error
+		if res, err = g.serveGet(dummyCtx, "key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 120 requests within a minute, less any fraction of them
+	// counted in the previous minute.
+	if qps := res.GetMinuteQps(); qps < 1 || qps > 2 {
+		t.Errorf("MinuteQps = %v; want between 1 and 2", qps)
+	}
+}
+
+func TestKeyRates(t *testing.T) {
+	var r keyRates
+	start := time.Unix(600, 0)
+	for i := 0; i < 60; i++ {
+		r.record("key", start.Add(time.Duration(i)*time.Second))
+	}
+	if qps := r.qps("key", start.Add(59*time.Second)); qps != 1 {
+		t.Errorf("qps after 60 requests in a minute = %v; want 1", qps)
+	}
+	if qps := r.qps("key", start.Add(90*time.Second)); qps != 0.5 {
+		t.Errorf("qps 30s later = %v; want 0.5", qps)
+	}
+	if qps := r.qps("key", start.Add(3*time.Minute)); qps != 0 {
+		t.Errorf("qps 2 minutes later = %v; want 0", qps)
+	}
+	if qps := r.qps("other", start); qps != 0 {
+		t.Errorf("qps of unknown key = %v; want 0", qps)
+	}
+}
+
+func TestCacheSplit(t *testing.T) {
+	const cacheBytes = 10000
+	g := newGroupOpts("TestCacheSplit-group", cacheBytes, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("ECHO:" + key)
+	}), nil, &GroupOptions{CacheSplit: func(SplitStats) float64 { return 0.5 }})
+	if s := g.CacheStats(HotCache); s.Share != defaultHotShare {
+		t.Errorf("hotCache Share before evicting = %v; want %v", s.Share, defaultHotShare)
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		g.populateCache(key, ByteView{s: "value"}, &g.mainCache)
+		g.populateCache(key, ByteView{s: "value"}, &g.hotCache)
+	}
+	main, hot := g.CacheStats(MainCache), g.CacheStats(HotCache)
+	if main.Share != 0.5 || hot.Share != 0.5 {
+		t.Errorf("Share = %v main, %v hot; want 0.5 each", main.Share, hot.Share)
+	}
+	if d := main.Bytes - hot.Bytes; d < -100 || d > 100 {
+		t.Errorf("caches hold %d main and %d hot bytes; want about the same", main.Bytes, hot.Bytes)
+	}
+}
+
+// TestCacheSplitZero tests that a CacheSplit may give the hotCache
+// no bytes at all.
+func TestCacheSplitZero(t *testing.T) {
+	const cacheBytes = 10000
+	g := newGroupOpts("TestCacheSplitZero-group", cacheBytes, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("ECHO:" + key)
+	}), nil, &GroupOptions{CacheSplit: func(SplitStats) float64 { return 0 }})
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		g.populateCache(key, ByteView{s: "value"}, &g.mainCache)
+		g.populateCache(key, ByteView{s: "value"}, &g.hotCache)
+	}
+	if s := g.CacheStats(HotCache); s.Share != 0 || s.Bytes > 100 {
+		t.Errorf("hotCache Share = %v, holding %d bytes; want 0 and about none", s.Share, s.Bytes)
+	}
+	if s := g.split.share(g, time.Now()); s != 0 {
+		t.Errorf("share = %v after deciding 0; want 0", s)
+	}
+}
+
+func TestAdaptiveSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		s    SplitStats
+		want float64
+	}{
+		{
+			name: "hot saves more",
+			s: SplitStats{
+				HotShare:    0.1,
+				Main:        CacheStats{Bytes: 9000, Hits: 10, Evictions: 5},
+				Hot:         CacheStats{Bytes: 1000, Hits: 10, Evictions: 5},
+				PeerLatency: time.Millisecond, LocalLatency: time.Millisecond,
+			},
+			want: 0.1 * hotShareStep,
+		},
+		{
+			name: "main saves more",
+			s: SplitStats{
+				HotShare:    0.1,
+				Main:        CacheStats{Bytes: 9000, Hits: 100, Evictions: 5},
+				Hot:         CacheStats{Bytes: 1000, Hits: 1, Evictions: 5},
+				PeerLatency: time.Millisecond, LocalLatency: time.Second,
+			},
+			want: 0.1 / hotShareStep,
+		},
+		{
+			name: "hot not full",
+			s: SplitStats{
+				HotShare:    0.1,
+				Main:        CacheStats{Bytes: 9000, Hits: 10, Evictions: 5},
+				Hot:         CacheStats{Bytes: 1000, Hits: 10},
+				PeerLatency: time.Millisecond, LocalLatency: time.Millisecond,
+			},
+			want: 0.1,
+		},
+		{
+			name: "at most half",
+			s: SplitStats{
+				HotShare:    maxHotShare,
+				Main:        CacheStats{Bytes: 5000, Evictions: 5},
+				Hot:         CacheStats{Bytes: 5000, Hits: 10, Evictions: 5},
+				PeerLatency: time.Millisecond,
+			},
+			want: maxHotShare,
+		},
+	}
+	for _, tt := range tests {
+		if got := AdaptiveSplit(tt.s); got != tt.want {
+			t.Errorf("%s: AdaptiveSplit = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	peer0 := &fakeMultiPeer{}
+	peer1 := &fakeMultiPeer{}
+	peerList := fakePeers([]ProtoGetter{peer0, peer1, nil})
+	errBroken := errors.New("broken")
+	broken := "broken"
+	for i := 0; ; i++ {
+		if _, ok := peerList.PickPeer(broken); !ok {
+			break // a key this process owns
+		}
+		broken = fmt.Sprintf("broken-%d", i)
+	}
+	var mu sync.Mutex
+	var localHits int
+	g := newGroup("TestGetMulti-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		mu.Lock()
+		localHits++
+		mu.Unlock()
+		if key == broken {
+			return errBroken
+		}
+		return dest.SetString("got:" + key)
+	}), peerList)
+
+	var keys []string
+	for i := 0; i < 30; i++ {
+		keys = append(keys, fmt.Sprintf("key-%d", i))
+	}
+	values := make([]string, len(keys))
+	dests := make([]Sink, len(keys))
+	for i := range dests {
+		dests[i] = StringSink(&values[i])
+	}
+	if err := g.GetMulti(dummyCtx, keys, dests); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		if want := "got:" + key; values[i] != want {
+			t.Errorf("value of %q = %q; want %q", key, values[i], want)
+		}
+	}
+	if peer0.batches != 1 || peer1.batches != 1 {
+		t.Errorf("peers got %d and %d requests; want 1 each", peer0.batches, peer1.batches)
+	}
+	if localHits+peer0.hits+peer1.hits != len(keys) {
+		t.Errorf("got %d local and %d+%d peer loads; want %d in all", localHits, peer0.hits, peer1.hits, len(keys))
+	}
+
+	// Errors are per key, and a failing peer's keys are loaded
+	// locally.
+	peer0.fail = true
+	keys = append(keys, broken)
+	dests = append(dests, StringSink(new(string)))
+	for i := range keys[:len(keys)-1] {
+		keys[i] += "-again"
+	}
+	err := g.GetMulti(dummyCtx, keys, dests)
+	merr, ok := err.(MultiError)
+	if !ok || len(merr) != len(keys) {
+		t.Fatalf("GetMulti = %v; want MultiError of %d errors", err, len(keys))
+	}
+	for i, err := range merr {
+		var want error
+		if keys[i] == broken {
+			want = errBroken
+		}
+		if err != want {
+			t.Errorf("error of %q = %v; want %v", keys[i], err, want)
+		}
+	}
+	if peer0.batches != 2 {
+		t.Errorf("failing peer got %d requests; want 2", peer0.batches)
+	}
+}
+
+// gatedMultiPeer is a fakeMultiPeer whose requests wait for gates.
+type gatedMultiPeer struct {
+	fakeMultiPeer
+	getGate, multiGate chan struct{}
+	getStarted         chan string   // receives the keys of Gets
+	multiStarted       chan []string // receives the keys of GetMultis
+}
+
+func (p *gatedMultiPeer) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	p.getStarted <- in.GetKey()
+	<-p.getGate
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.fakeMultiPeer.Get(ctx, in, out)
+}
+
+func (p *gatedMultiPeer) GetMulti(ctx context.Context, in *pb.GetMultiRequest, out *pb.GetMultiResponse) error {
+	p.multiStarted <- in.Keys
+	<-p.multiGate
+	return p.fakeMultiPeer.GetMulti(ctx, in, out)
+}
+
+// TestGetMultiJoinsLoads tests that GetMulti and Get share the loads
+// of keys in flight.
+func TestGetMultiJoinsLoads(t *testing.T) {
+	peer := &gatedMultiPeer{
+		getGate:      make(chan struct{}),
+		multiGate:    make(chan struct{}),
+		getStarted:   make(chan string, 10),
+		multiStarted: make(chan []string, 10),
+	}
+	g := newGroup("TestGetMultiJoinsLoads-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local:" + key)
+	}), fakePeers{peer})
+	get := func(key string) <-chan string {
+		ch := make(chan string, 1)
+		go func() {
+			var s string
+			g.Get(dummyCtx, key, StringSink(&s))
+			ch <- s
+		}()
+		return ch
+	}
+	getMulti := func(keys ...string) <-chan []string {
+		ch := make(chan []string, 1)
+		go func() {
+			values := make([]string, len(keys))
+			dests := make([]Sink, len(keys))
+			for i := range dests {
+				dests[i] = StringSink(&values[i])
+			}
+			g.GetMulti(dummyCtx, keys, dests)
+			ch <- values
+		}()
+		return ch
+	}
+
+	// A GetMulti joins a Get in flight.
+	slow := get("slow")
+	<-peer.getStarted
+	multi := getMulti("slow", "other")
+	if keys := <-peer.multiStarted; !reflect.DeepEqual(keys, []string{"other"}) {
+		t.Errorf("GetMulti requested %q; want only other", keys)
+	}
+	close(peer.multiGate)
+	close(peer.getGate)
+	if s := <-slow; s != "got:slow" {
+		t.Errorf("Get = %q; want got:slow", s)
+	}
+	if values := <-multi; !reflect.DeepEqual(values, []string{"got:slow", "got:other"}) {
+		t.Errorf("GetMulti = %q; want got:slow, got:other", values)
+	}
+
+	// A Get joins a GetMulti in flight.
+	peer.multiGate = make(chan struct{})
+	multi = getMulti("batched")
+	<-peer.multiStarted
+	loads := g.Stats.Loads.Get()
+	single := get("batched")
+	for g.Stats.Loads.Get() == loads {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond) // for the Get to join the load
+	close(peer.multiGate)
+	if s := <-single; s != "got:batched" {
+		t.Errorf("Get = %q; want got:batched", s)
+	}
+	if values := <-multi; values[0] != "got:batched" {
+		t.Errorf("GetMulti = %q; want got:batched", values)
+	}
+	select {
+	case key := <-peer.getStarted:
+		t.Errorf("Get of %q went to the peer; want it to join the GetMulti", key)
+	default:
+	}
+}
+
+// TestGetMultiLimits tests that GetMulti splits large batches and
+// that peers serve the keys of a batch with bounded concurrency.
+func TestGetMultiLimits(t *testing.T) {
+	peer := &fakeMultiPeer{}
+	var running, maxRunning int32
+	var mu sync.Mutex
+	g := newGroup("TestGetMultiLimits-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		mu.Lock()
+		if n > maxRunning {
+			maxRunning = n
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		return dest.SetString(key)
+	}), fakePeers{peer})
+
+	keys := make([]string, maxGetMultiKeys+1)
+	dests := make([]Sink, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		dests[i] = StringSink(new(string))
+	}
+	if err := g.GetMulti(dummyCtx, keys, dests); err != nil {
+		t.Fatal(err)
+	}
+	if peer.batches != 2 {
+		t.Errorf("peer got %d requests for %d keys; want 2", peer.batches, len(keys))
+	}
+
+	if _, err := g.serveGetMulti(dummyCtx, keys); err != errTooManyKeys {
+		t.Errorf("serveGetMulti of %d keys = %v; want errTooManyKeys", len(keys), err)
+	}
+	local := newGroup("TestGetMultiLimits-local", cacheSize, g.getter, fakePeers{nil})
+	res, err := local.serveGetMulti(dummyCtx, keys[:100])
+	if err != nil || len(res.Responses) != 100 {
+		t.Fatalf("serveGetMulti = %v, %v; want 100 responses", res, err)
+	}
+	if maxRunning > getMultiWorkers {
+		t.Errorf("served %d keys at once; want at most %d", maxRunning, getMultiWorkers)
+	}
+}
+
+func TestReplicas(t *testing.T) {
+	primary, replica := &fakePeer{}, &fakePeer{}
+	picker := &fakeReplicas{owners: []ProtoGetter{primary, replica, nil}}
+	var fills int
+	g := newGroup("TestReplicas-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills++
+		return dest.SetString("local:" + key)
+	}), picker)
+	get := func(key string) string {
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	// A failed primary falls back to the replica.
+	primary.fail = true
+	if s := get("a"); s != "got:a" || primary.hits != 1 || replica.hits != 1 || fills != 0 {
+		t.Errorf("Get = %q with hits %d, %d and %d fills; want the replica's value", s, primary.hits, replica.hits, fills)
+	}
+	if n := g.Stats.ReplicaLoads.Get(); n != 1 {
+		t.Errorf("ReplicaLoads = %d; want 1", n)
+	}
+
+	// When all owners fail, the key is loaded locally and sent to
+	// them.
+	replica.fail = true
+	if s := get("b"); s != "local:b" || fills != 1 {
+		t.Errorf("Get = %q with %d fills; want a local load", s, fills)
+	}
+	for deadline := time.Now().Add(time.Second); g.Stats.ReplicaFillErrs.Get() < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if n := g.Stats.ReplicaFillErrs.Get(); n != 2 {
+		t.Errorf("ReplicaFillErrs = %d; want 2", n)
+	}
+	replica.fail = false
+	if s := get("c"); s != "got:c" {
+		t.Fatalf("Get = %q; want the replica's value", s)
+	}
+
+	// An owner loads keys itself after asking the owners before it,
+	// and sends them to the others.
+	picker.owners = []ProtoGetter{primary, nil, replica}
+	hits := replica.hits
+	if s := get("d"); s != "local:d" || replica.hits != hits {
+		t.Errorf("Get = %q with %d replica hits; want a local load", s, replica.hits-hits)
+	}
+	if v, ok := replica.waitSet("d"); v != "local:d" {
+		t.Errorf("replica was sent %q (%v); want %q", v, ok, "local:d")
+	}
+	picker.owners = []ProtoGetter{nil, replica}
+	primary.hits = 0
+	if s := get("e"); s != "local:e" || primary.hits != 0 {
+		t.Errorf("Get = %q; want a local load", s)
+	}
+	if v, ok := replica.waitSet("e"); v != "local:e" {
+		t.Errorf("replica was sent %q (%v); want %q", v, ok, "local:e")
+	}
+
+	// Set reaches every owner.
+	picker.owners = []ProtoGetter{replica, nil}
+	if err := g.Set(dummyCtx, "f", []byte("set"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := replica.waitSet("f"); v != "set" {
+		t.Errorf("replica was sent %q (%v); want %q", v, ok, "set")
+	}
+	if v, ok := g.mainCache.get("f"); !ok || v.String() != "set" {
+		t.Errorf("mainCache has %q (%v); want %q", v, ok, "set")
+	}
+	if n := g.Stats.ReplicaFills.Get(); n != 5 {
+		t.Errorf("ReplicaFills = %d; want 5", n)
+	}
+}
+
+// stuckSetPeer is a peer whose Sets wait until they time out.
+type stuckSetPeer struct {
+	fakePeer
+	running, maxRunning int32
+}
+
+func (p *stuckSetPeer) Set(ctx context.Context, in *pb.SetRequest) error {
+	n := atomic.AddInt32(&p.running, 1)
+	defer atomic.AddInt32(&p.running, -1)
+	for {
+		max := atomic.LoadInt32(&p.maxRunning)
+		if n <= max || atomic.CompareAndSwapInt32(&p.maxRunning, max, n) {
+			break
+		}
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestReplicaFillLimits(t *testing.T) {
+	peer := &stuckSetPeer{}
+	g := newGroupOpts("TestReplicaFillLimits-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	}), fakePeers{nil}, &GroupOptions{
+		ReplicaFillTimeout: 10 * time.Millisecond,
+	})
+	const n = replicaFillQueue + 100
+	for i := 0; i < n; i++ {
+		g.fillReplicas(fmt.Sprintf("key-%d", i), ByteView{s: "value"}, []ProtoGetter{nil, peer})
+	}
+	// The fills beyond the queue are dropped, and the others time
+	// out.
+	if dropped := g.Stats.ReplicaFillErrs.Get(); dropped < n-replicaFillQueue-replicaFillWorkers {
+		t.Errorf("%d fills dropped; want at least %d", dropped, n-replicaFillQueue-replicaFillWorkers)
+	}
+	for deadline := time.Now().Add(10 * time.Second); g.Stats.ReplicaFillErrs.Get() < n && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if errs := g.Stats.ReplicaFillErrs.Get(); errs != n {
+		t.Errorf("ReplicaFillErrs = %d; want %d", errs, n)
+	}
+	if max := atomic.LoadInt32(&peer.maxRunning); max > replicaFillWorkers {
+		t.Errorf("%d fills at once; want at most %d", max, replicaFillWorkers)
+	}
+}
+
+func TestLoadCancel(t *testing.T) {
+	started := make(chan context.Context, 1)
+	release := make(chan struct{})
+	g := newGroup("TestLoadCancel-group", cacheSize, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		started <- ctx
+		if key == "never" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return dest.SetString("got:" + key)
+	}), nil)
+
+	// An impatient caller gives up alone.
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	res1 := make(chan error, 1)
+	go func() {
+		var s string
+		res1 <- g.Get(ctx1, "key", StringSink(&s))
+	}()
+	getterCtx := <-started
+	res2 := make(chan string, 1)
+	go func() {
+		var s string
+		if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+			s = "ERROR:" + err.Error()
+		}
+		res2 <- s
+	}()
+	for g.Stats.Loads.Get() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel1()
+	if err := <-res1; err != context.Canceled {
+		t.Errorf("cancelled Get error = %v; want context.Canceled", err)
+	}
+	if err := getterCtx.Err(); err != nil {
+		t.Errorf("Getter's context ended with %v while a caller waits", err)
+	}
+	close(release)
+	if s := <-res2; s != "got:key" {
+		t.Errorf("Get = %q; want %q", s, "got:key")
+	}
+
+	// When every caller gives up, so does the Getter.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var s string
+	if err := g.Get(ctx, "never", StringSink(&s)); err != context.DeadlineExceeded {
+		t.Errorf("Get error = %v; want context.DeadlineExceeded", err)
+	}
+	if err := (<-started).Err(); err != context.Canceled {
+		t.Errorf("Getter's context ended with %v; want context.Canceled", err)
+	}
+}
+
+func TestRemoveForgetsLoad(t *testing.T) {
+	release := make(chan struct{})
+	var fills int32
+	g := newGroup("TestRemoveForgetsLoad-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if atomic.AddInt32(&fills, 1) == 1 {
+			<-release
+			return dest.SetString("stale")
+		}
+		return dest.SetString("fresh")
+	}), fakePeers{nil})
+
+	stale := make(chan string)
+	go func() {
+		var s string
+		g.Get(dummyCtx, "key", StringSink(&s))
+		stale <- s
+	}()
+	for atomic.LoadInt32(&fills) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := g.Remove(dummyCtx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil || s != "fresh" {
+		t.Errorf("Get after Remove = %q, %v; want a fresh load", s, err)
+	}
+	close(release)
+	if s := <-stale; s != "stale" {
+		t.Errorf("Get in flight = %q; want %q", s, "stale")
+	}
+}
+
+func TestGetterPanic(t *testing.T) {
+	var fills int
+	g := newGroup("TestGetterPanic-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if fills++; fills == 1 {
+			panic("boom")
+		}
+		return dest.SetString("got:" + key)
+	}), fakePeers{nil})
+	func() {
+		defer func() {
+			if p, ok := recover().(*singleflight.PanicError); !ok || p.Value != "boom" {
+				t.Errorf("Get panicked with %v; want the Getter's panic", p)
+			}
+		}()
+		var s string
+		g.Get(dummyCtx, "key", StringSink(&s))
+	}()
+
+	// The key is loaded again rather than left stuck.
+	var s string
+	if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil || s != "got:key" {
+		t.Errorf("Get after panic = %q, %v; want %q", s, err, "got:key")
+	}
+}
+
+// TestGetMultiPanic tests that a Getter's panic reaches the caller of
+// GetMulti, whether the key is loaded locally or after its owner fails,
+// and that a peer asking for the key gets an error instead.
+func TestGetMultiPanic(t *testing.T) {
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if key == "boom" {
+			panic("boom")
+		}
+		return dest.SetString("got:" + key)
+	})
+	getMulti := func(g *Group, keys ...string) {
+		dests := make([]Sink, len(keys))
+		for i := range dests {
+			dests[i] = StringSink(new(string))
+		}
+		g.GetMulti(dummyCtx, keys, dests)
+	}
+	peer := &fakeMultiPeer{fakePeer: fakePeer{fail: true}}
+	for name, g := range map[string]*Group{
+		"local": newGroup("TestGetMultiPanic-local", cacheSize, getter, fakePeers{nil}),
+		"peer":  newGroup("TestGetMultiPanic-peer", cacheSize, getter, fakePeers{peer}),
+	} {
+		func() {
+			defer func() {
+				if p, ok := recover().(*singleflight.PanicError); !ok || p.Value != "boom" {
+					t.Errorf("%s: GetMulti panicked with %v; want the Getter's panic", name, p)
+				}
+			}()
+			getMulti(g, "a", "boom", "b")
+		}()
+	}
+
+	owner := newGroup("TestGetMultiPanic-owner", cacheSize, getter, fakePeers{nil})
+	res, err := owner.serveGetMulti(dummyCtx, []string{"a", "boom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(res.Responses[0].Value); got != "got:a" {
+		t.Errorf("value of a = %q; want %q", got, "got:a")
+	}
+	if msg := res.Responses[1].GetErrorMessage(); !strings.Contains(msg, "panicked: boom") {
+		t.Errorf("error of boom = %q; want the Getter's panic", msg)
+	}
+}
+
+func TestLoadSharing(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var traces []LoadTrace
+	g := newGroupOpts("TestLoadSharing-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		<-release
+		return dest.SetString("got:" + key)
+	}), fakePeers{nil}, &GroupOptions{
+		TraceLoad: func(tr LoadTrace) {
+			mu.Lock()
+			traces = append(traces, tr)
+			mu.Unlock()
+		},
+	})
+
+	const n = 5
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var s string
+			if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for g.Stats.Loads.Get() < n {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond) // let the loads join
+	close(release)
+	wg.Wait()
+
+	if shared, waiters := g.Stats.LoadsShared.Get(), g.Stats.LoadWaiters.Get(); shared != 1 || waiters != n-1 {
+		t.Errorf("LoadsShared = %d, LoadWaiters = %d; want 1 and %d", shared, waiters, n-1)
+	}
+	var joined int
+	for _, tr := range traces {
+		if tr.Key != "key" || tr.Dups != n-1 || tr.Err != nil {
+			t.Errorf("trace %+v; want key with %d dups", tr, n-1)
+		}
+		if tr.Joined {
+			joined++
+		}
+	}
+	if len(traces) != n || joined != n-1 {
+		t.Errorf("%d traces, %d joined; want %d, %d", len(traces), joined, n, n-1)
+	}
+}
+
 func TestTruncatingByteSliceTarget(t *testing.T) {
 	var buf [100]byte
 	s := buf[:]
@@ -375,12 +1826,20 @@ type orderedFlightGroup struct {
 	orig   flightGroup
 }
 
-func (g *orderedFlightGroup) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+func (g *orderedFlightGroup) DoContext(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error, int) {
 	<-g.stage1
 	<-g.stage2
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	return g.orig.Do(key, fn)
+	return g.orig.DoContext(ctx, key, fn)
+}
+
+func (g *orderedFlightGroup) Start(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (<-chan singleflight.Result, bool) {
+	return g.orig.Start(ctx, key, fn)
+}
+
+func (g *orderedFlightGroup) Forget(key string) {
+	g.orig.Forget(key)
 }
 
 // TestNoDedup tests invariants on the cache size when singleflight is
@@ -398,12 +1857,12 @@ func TestNoDedup(t *testing.T) {
 		orig:   g.loadGroup,
 	}
 	// Replace loadGroup with our wrapper so we can control when
-	// loadGroup.Do is entered for each concurrent request.
+	// loadGroup.DoContext is entered for each concurrent request.
 	g.loadGroup = orderedGroup
 
 	// Issue two idential requests concurrently.  Since the cache is
 	// empty, it will miss.  Both will enter load(), but we will only
-	// allow one at a time to enter singleflight.Do, so the callback
+	// allow one at a time to enter singleflight.DoContext, so the callback
 	// function will be called twice.
 	resc := make(chan string, 2)
 	for i := 0; i < 2; i++ {
@@ -440,18 +1899,159 @@ func TestNoDedup(t *testing.T) {
 	// upon entry, we would increment nbytes twice but the entry would
 	// only be in the cache once.
 	const wantBytes = int64(len(testkey) + len(testval))
-	if g.mainCache.nbytes != wantBytes {
-		t.Errorf("cache has %d bytes, want %d", g.mainCache.nbytes, wantBytes)
+	if g.mainCache.bytes() != wantBytes {
+		t.Errorf("cache has %d bytes, want %d", g.mainCache.bytes(), wantBytes)
 	}
 }
 
+// TestGroupStatsAlignment tests that the counters of a group can be
+// used atomically. Run it with GOARCH=386 to check 32-bit platforms.
 func TestGroupStatsAlignment(t *testing.T) {
-	var g Group
-	off := unsafe.Offsetof(g.Stats)
-	if off%8 != 0 {
-		t.Fatal("Stats structure is not 8-byte aligned.")
+	g := newGroup("TestGroupStatsAlignment-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	}), fakePeers{nil})
+	v := reflect.ValueOf(&g.Stats).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if addr := v.Field(i).UnsafeAddr(); addr%8 != 0 {
+			t.Errorf("Stats.%s is not 8-byte aligned", v.Type().Field(i).Name)
+		}
+	}
+	// On 32-bit platforms, unaligned counters panic.
+	var s string
+	if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+		t.Fatal(err)
 	}
 }
 
 // TODO(bradfitz): port the Google-internal full integration test into here,
 // using HTTP requests instead of our RPC system.
+
+func BenchmarkCacheGet(b *testing.B) {
+	var c cache
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		c.add(keys[i], ByteView{s: keys[i]})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := c.get(keys[i&1023]); !ok {
+			b.Fatal("missing key")
+		}
+	}
+}
+
+func BenchmarkCacheAdd(b *testing.B) {
+	var c cache
+	keys := make([]string, 4096)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[i&4095]
+		c.add(key, ByteView{s: key})
+		if c.items() > 1024 {
+			c.removeOldest()
+		}
+	}
+}
+
+// BenchmarkCacheGetParallel compares concurrent gets of a cache with
+// one lock, shards=1, against caches with several shards.
+func BenchmarkCacheGetParallel(b *testing.B) {
+	for _, shards := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := &cache{nshards: shards}
+			keys := make([]string, 1024)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key-%d", i)
+				c.add(keys[i], ByteView{s: keys[i]})
+			}
+			var seed atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(seed.Add(1)) * 7919
+				for pb.Next() {
+					if _, ok := c.get(keys[i&1023]); !ok {
+						b.Error("missing key")
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkCacheMixedParallel runs concurrent gets with one add and
+// eviction in ten operations, as a cache filling from misses does.
+func BenchmarkCacheMixedParallel(b *testing.B) {
+	for _, shards := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := &cache{nshards: shards}
+			keys := make([]string, 4096)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key-%d", i)
+				if i < 1024 {
+					c.add(keys[i], ByteView{s: keys[i]})
+				}
+			}
+			maxBytes := c.bytes()
+			var seed atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(seed.Add(1)) * 7919
+				for pb.Next() {
+					key := keys[i&4095]
+					if i%10 == 0 {
+						c.add(key, ByteView{s: key})
+						for c.bytes() > maxBytes {
+							c.removeOldest()
+						}
+					} else {
+						c.get(key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkGroupGetParallel measures concurrent Gets of keys in a
+// group's cache, which also record the rates of the keys, with one
+// cache shard and with the default number.
+func BenchmarkGroupGetParallel(b *testing.B) {
+	for _, shards := range []int{1, 0} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			g := NewWorkspace().newGroupOpts("BenchmarkGroupGetParallel", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+				return dest.SetString(key)
+			}), fakePeers{nil}, &GroupOptions{CacheShards: shards})
+			keys := make([]string, 1024)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key-%d", i)
+				var s string
+				g.Get(dummyCtx, keys[i], StringSink(&s))
+			}
+			var seed atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(seed.Add(1)) * 7919
+				var s string
+				for pb.Next() {
+					if err := g.Get(dummyCtx, keys[i&1023], StringSink(&s)); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// groupcachez.go serves a page for debugging the groups of a process.
+
+package groupcache
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DebugPath is the conventional path of the handler of DebugHandler.
+const DebugPath = "/groupcachez"
+
+// defaultTopKeys is the number of top keys shown of each group.
+const defaultTopKeys = 10
+
+// DebugHandler returns a handler serving a page for debugging the
+// groups of DefaultWorkspace; see Workspace.DebugHandler.
+func DebugHandler() http.Handler {
+	return DefaultWorkspace.DebugHandler()
+}
+
+// DebugHandler returns a handler serving a page for debugging the
+// groups of ws: their stats, cache sizes, recent peer errors and top
+// keys, and the health of the peers of the workspace's HTTPPool, if
+// any. The page is HTML, or JSON if the request has a format=json
+// query parameter or accepts application/json. A top query parameter
+// sets the number of top keys of each group, 10 by default.
+//
+// The handler can be mounted alongside the HTTPPool:
+//
+//	http.Handle(groupcache.DebugPath, ws.DebugHandler())
+func (ws *Workspace) DebugHandler() http.Handler {
+	return http.HandlerFunc(ws.serveDebug)
+}
+
+// debugPage is the data of the debug page.
+type debugPage struct {
+	Time   time.Time
+	Self   string       `json:",omitempty"` // the HTTPPool's own address
+	Peers  []PeerHealth `json:",omitempty"` // the HTTPPool's peers
+	Groups []debugGroup
+}
+
+type debugGroup struct {
+	Name       string
+	CacheBytes int64 // the group's limit
+	Stats      map[string]int64
+	MainCache  CacheStats
+	HotCache   CacheStats
+	PeerErrors []PeerError
+	TopKeys    []KeyQPS
+}
+
+func (ws *Workspace) serveDebug(w http.ResponseWriter, r *http.Request) {
+	top := defaultTopKeys
+	if s := r.FormValue("top"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "bad top: "+s, http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+	page := debugPage{Time: time.Now()}
+	if p := ws.pool(); p != nil {
+		page.Self = p.self
+		page.Peers = p.Health()
+	}
+	for _, g := range ws.Groups() {
+		page.Groups = append(page.Groups, debugGroup{
+			Name:       g.name,
+			CacheBytes: g.cacheBytes,
+			Stats:      g.Stats.snapshot(),
+			MainCache:  g.CacheStats(MainCache),
+			HotCache:   g.CacheStats(HotCache),
+			PeerErrors: g.RecentPeerErrors(),
+			TopKeys:    g.TopKeys(top),
+		})
+	}
+
+	if r.FormValue("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(page)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	debugTemplate.Execute(w, page)
+}
+
+// snapshot returns the values of s, keyed by field name.
+func (s *Stats) snapshot() map[string]int64 {
+	v := reflect.ValueOf(s).Elem()
+	m := make(map[string]int64, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		m[v.Type().Field(i).Name] = v.Field(i).Addr().Interface().(*AtomicInt).Get()
+	}
+	return m
+}
+
+var debugTemplate = template.Must(template.New("groupcachez").Parse(`<!DOCTYPE html>
+<html>
+<head><title>groupcachez</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>groupcachez</h1>
+<p>{{.Time.Format "2006-01-02 15:04:05 MST"}}</p>
+{{if .Peers}}
+<h2>Peers</h2>
+<p>Self: {{.Self}}</p>
+<table>
+<tr><th>Peer</th><th>In ring</th><th>Ejected until</th><th>Probing</th><th>In flight</th><th>Latency</th><th>Successes</th><th>Failures</th><th>Ejections</th></tr>
+{{range .Peers}}<tr><td>{{.Peer}}</td><td>{{not .Ejected}}</td><td>{{if .Ejected}}{{.EjectedUntil.Format "15:04:05"}}{{end}}</td><td>{{.Probing}}</td><td>{{.InFlight}}</td><td>{{.Latency}}</td><td>{{.Successes}}</td><td>{{.Failures}}</td><td>{{.Ejections}}</td></tr>
+{{end}}</table>
+{{end}}
+{{range .Groups}}
+<h2>Group {{.Name}}</h2>
+<table>
+<tr><th>Cache</th><th>Bytes</th><th>Items</th><th>Gets</th><th>Hits</th><th>Evictions</th><th>Rejects</th><th>Share</th></tr>
+<tr><td>main</td><td>{{.MainCache.Bytes}}</td><td>{{.MainCache.Items}}</td><td>{{.MainCache.Gets}}</td><td>{{.MainCache.Hits}}</td><td>{{.MainCache.Evictions}}</td><td>{{.MainCache.Rejects}}</td><td>{{printf "%.3f" .MainCache.Share}}</td></tr>
+<tr><td>hot</td><td>{{.HotCache.Bytes}}</td><td>{{.HotCache.Items}}</td><td>{{.HotCache.Gets}}</td><td>{{.HotCache.Hits}}</td><td>{{.HotCache.Evictions}}</td><td>{{.HotCache.Rejects}}</td><td>{{printf "%.3f" .HotCache.Share}}</td></tr>
+</table>
+<p>Cache bytes limit: {{.CacheBytes}}</p>
+<h3>Stats</h3>
+<table>
+{{range $name, $value := .Stats}}<tr><td>{{$name}}</td><td>{{$value}}</td></tr>
+{{end}}</table>
+<h3>Top keys</h3>
+{{if .TopKeys}}<table>
+<tr><th>Key</th><th>QPS</th></tr>
+{{range .TopKeys}}<tr><td>{{.Key}}</td><td>{{printf "%.3f" .QPS}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+<h3>Recent peer errors</h3>
+{{if .PeerErrors}}<table>
+<tr><th>Time</th><th>Peer</th><th>Key</th><th>Error</th></tr>
+{{range .PeerErrors}}<tr><td>{{.Time.Format "15:04:05.000"}}</td><td>{{.Peer}}</td><td>{{.Key}}</td><td>{{.Err}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,294 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"groupcache/consistenthash"
+	pb "groupcache/groupcachepb"
+)
+
+// GRPCPool implements PeerPicker for a pool of gRPC peers.
+type GRPCPool struct {
+	// this peer's address, e.g. "10.0.0.1:8000"
+	self string
+
+	// ws is the workspace whose groups the pool serves.
+	ws *Workspace
+
+	// opts specifies the options.
+	opts GRPCPoolOptions
+
+	mu          sync.Mutex // guards peers and grpcGetters
+	peers       *consistenthash.Map
+	grpcGetters map[string]*grpcGetter // keyed by e.g. "10.0.0.2:8008"
+}
+
+// GRPCPoolOptions are the configurations of a GRPCPool.
+type GRPCPoolOptions struct {
+	// Replicas specifies the number of key replicas on the consistent hash.
+	// If blank, it defaults to 50.
+	Replicas int
+
+	// HashFn specifies the hash function of the consistent hash.
+	// If blank, it defaults to crc32.ChecksumIEEE.
+	HashFn consistenthash.Hash
+
+	// Replication specifies the number of peers owning each key,
+	// the first of them as its primary owner and the others as
+	// replicas; see ReplicaPicker.
+	// If blank, it defaults to 1.
+	Replication int
+
+	// DialOptions specifies the options used to connect to peers,
+	// such as transport credentials or a load-balancing policy.
+	// If blank, peers are connected to without transport security.
+	DialOptions []grpc.DialOption
+}
+
+// NewGRPCPool initializes a gRPC pool of peers, and registers itself as a PeerPicker.
+// The self argument should be the gRPC target of the current server,
+// for example "example.net:8000".
+// The returned *GRPCPool must be registered with a gRPC server using Register.
+func NewGRPCPool(self string, o *GRPCPoolOptions) *GRPCPool {
+	return DefaultWorkspace.NewGRPCPool(self, o)
+}
+
+// NewGRPCPool initializes a gRPC pool of peers for the groups of ws,
+// and registers itself as the PeerPicker of ws.
+// See the package-level NewGRPCPool.
+func (ws *Workspace) NewGRPCPool(self string, o *GRPCPoolOptions) *GRPCPool {
+	p := &GRPCPool{
+		self:        self,
+		ws:          ws,
+		grpcGetters: make(map[string]*grpcGetter),
+	}
+	if o != nil {
+		p.opts = *o
+	}
+	if p.opts.Replicas == 0 {
+		p.opts.Replicas = defaultReplicas
+	}
+	if len(p.opts.DialOptions) == 0 {
+		p.opts.DialOptions = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+
+	ws.RegisterPeerPicker(func() PeerPicker { return p })
+	return p
+}
+
+// Register registers the pool's GroupCache service with s, so that
+// the other peers can fetch keys owned by this one.
+func (p *GRPCPool) Register(s grpc.ServiceRegistrar) {
+	pb.RegisterGroupCacheServer(s, &grpcServer{ws: p.ws})
+}
+
+// Set updates the pool's list of peers.
+// Each peer value should be a valid gRPC target,
+// for example "example.net:8000".
+// Connections to peers that remain in the pool are kept open.
+func (p *GRPCPool) Set(peers ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	getters := make(map[string]*grpcGetter, len(peers))
+	for _, peer := range peers {
+		if peer == p.self {
+			continue
+		}
+		if getter, ok := p.grpcGetters[peer]; ok {
+			getters[peer] = getter
+			continue
+		}
+		conn, err := grpc.NewClient(peer, p.opts.DialOptions...)
+		if err != nil {
+			for peer, getter := range getters {
+				if p.grpcGetters[peer] != getter {
+					getter.conn.Close()
+				}
+			}
+			return fmt.Errorf("connecting to peer %q: %v", peer, err)
+		}
+		getters[peer] = &grpcGetter{conn: conn, client: pb.NewGroupCacheClient(conn)}
+	}
+	for peer, getter := range p.grpcGetters {
+		if _, ok := getters[peer]; !ok {
+			getter.conn.Close()
+		}
+	}
+	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	p.peers.Add(peers...)
+	p.grpcGetters = getters
+	return nil
+}
+
+// Close closes the connections to all peers.
+func (p *GRPCPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	for _, getter := range p.grpcGetters {
+		if cerr := getter.conn.Close(); err == nil {
+			err = cerr
+		}
+	}
+	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	p.grpcGetters = make(map[string]*grpcGetter)
+	return err
+}
+
+func (p *GRPCPool) PickPeer(key string) (ProtoGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers.IsEmpty() {
+		return nil, false
+	}
+	if peer := p.peers.Get(key); peer != p.self {
+		return p.grpcGetters[peer], true
+	}
+	return nil, false
+}
+
+// PickPeers returns the Replication owners of key, with nil standing
+// for this process, or nil if Replication is below 2.
+func (p *GRPCPool) PickPeers(key string) []ProtoGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.opts.Replication < 2 {
+		return nil
+	}
+	nodes := p.peers.GetN(key, p.opts.Replication)
+	if len(nodes) == 0 {
+		return nil
+	}
+	owners := make([]ProtoGetter, len(nodes))
+	for i, peer := range nodes {
+		if peer != p.self {
+			owners[i] = p.grpcGetters[peer]
+		}
+	}
+	return owners
+}
+
+// GetAll returns the peers in the pool other than this one.
+func (p *GRPCPool) GetAll() []ProtoGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := make([]ProtoGetter, 0, len(p.grpcGetters))
+	for _, getter := range p.grpcGetters {
+		res = append(res, getter)
+	}
+	return res
+}
+
+// grpcServer implements the GroupCache service for the groups of a
+// workspace.
+type grpcServer struct {
+	ws *Workspace
+}
+
+func (s *grpcServer) group(name string) (*Group, error) {
+	group := s.ws.GetGroup(name)
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "no such group: %s", name)
+	}
+	return group, nil
+}
+
+func (s *grpcServer) Get(ctx context.Context, in *pb.GetRequest) (*pb.GetResponse, error) {
+	group, err := s.group(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	return group.serveGet(ctx, in.GetKey())
+}
+
+func (s *grpcServer) Set(ctx context.Context, in *pb.SetRequest) (*pb.SetResponse, error) {
+	group, err := s.group(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	group.serveSet(in.GetKey(), in)
+	return &pb.SetResponse{}, nil
+}
+
+func (s *grpcServer) Remove(ctx context.Context, in *pb.GetRequest) (*pb.RemoveResponse, error) {
+	group, err := s.group(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	group.localRemove(in.GetKey())
+	return &pb.RemoveResponse{}, nil
+}
+
+func (s *grpcServer) GetMulti(ctx context.Context, in *pb.GetMultiRequest) (*pb.GetMultiResponse, error) {
+	group, err := s.group(in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	res, err := group.serveGetMulti(ctx, in.Keys)
+	if err == errTooManyKeys {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return res, err
+}
+
+// grpcGetter is a peer reached over a long-lived gRPC connection.
+type grpcGetter struct {
+	conn   *grpc.ClientConn
+	client pb.GroupCacheClient
+}
+
+func (g *grpcGetter) String() string {
+	return g.conn.Target()
+}
+
+func (g *grpcGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	res, err := g.client.Get(ctx, in)
+	if err != nil {
+		return err
+	}
+	proto.Merge(out, res)
+	return nil
+}
+
+func (g *grpcGetter) GetMulti(ctx context.Context, in *pb.GetMultiRequest, out *pb.GetMultiResponse) error {
+	res, err := g.client.GetMulti(ctx, in)
+	if err != nil {
+		return err
+	}
+	proto.Merge(out, res)
+	return nil
+}
+
+func (g *grpcGetter) Remove(ctx context.Context, in *pb.GetRequest) error {
+	_, err := g.client.Remove(ctx, in)
+	return err
+}
+
+func (g *grpcGetter) Set(ctx context.Context, in *pb.SetRequest) error {
+	_, err := g.client.Set(ctx, in)
+	return err
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	pb "groupcache/groupcachepb"
+)
+
+func TestGRPCPool(t *testing.T) {
+	const nPeers = 3
+	var (
+		addrs  []string
+		pools  []*GRPCPool
+		groups []*Group
+	)
+	for i := 0; i < nPeers; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := l.Addr().String()
+		addrs = append(addrs, addr)
+
+		ws := NewWorkspace()
+		p := ws.NewGRPCPool(addr, nil)
+		defer p.Close()
+		s := grpc.NewServer()
+		p.Register(s)
+		go s.Serve(l)
+		defer s.Stop()
+		pools = append(pools, p)
+
+		prefix := strconv.Itoa(i) + ":"
+		groups = append(groups, ws.NewGroup("grpcPoolTest", 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			return dest.SetString(prefix + key)
+		})))
+	}
+	for _, p := range pools {
+		if err := p.Set(addrs...); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(p.GetAll()); n != nPeers-1 {
+			t.Errorf("GetAll returned %d peers, want %d", n, nPeers-1)
+		}
+	}
+
+	owners := make(map[string]bool)
+	for _, key := range testKeys(50) {
+		var value string
+		if err := groups[0].Get(context.TODO(), key, StringSink(&value)); err != nil {
+			t.Fatal(err)
+		}
+		if suffix := ":" + key; !strings.HasSuffix(value, suffix) {
+			t.Errorf("Get(%q) = %q, want value ending in %q", key, value, suffix)
+		}
+		owners[strings.TrimSuffix(value, ":"+key)] = true
+	}
+	if len(owners) != nPeers {
+		t.Errorf("keys loaded by %d peers, want %d", len(owners), nPeers)
+	}
+	if n := groups[0].Stats.PeerErrors.Get(); n != 0 {
+		t.Errorf("%d peer errors, want 0", n)
+	}
+
+	// GetMulti fetches the keys of each peer in one request.
+	keys := testKeys(30)
+	values := make([]string, len(keys))
+	dests := make([]Sink, len(keys))
+	for i := range dests {
+		dests[i] = StringSink(&values[i])
+	}
+	if err := groups[1].GetMulti(context.TODO(), keys, dests); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		if suffix := ":" + key; !strings.HasSuffix(values[i], suffix) {
+			t.Errorf("GetMulti value of %q = %q, want value ending in %q", key, values[i], suffix)
+		}
+	}
+	if n := groups[1].Stats.PeerErrors.Get(); n != 0 {
+		t.Errorf("%d peer errors after GetMulti, want 0", n)
+	}
+	var res pb.GetMultiResponse
+	err := pools[1].grpcGetters[addrs[0]].GetMulti(context.TODO(), &pb.GetMultiRequest{
+		Group: proto.String("grpcPoolTest"),
+		Keys:  make([]string, maxGetMultiKeys+1),
+	}, &res)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetMulti of %d keys: %v, want InvalidArgument", maxGetMultiKeys+1, err)
+	}
+
+	for _, key := range testKeys(10) {
+		want := "set:" + key
+		if err := groups[0].Set(context.TODO(), key, []byte(want), nil); err != nil {
+			t.Fatal(err)
+		}
+		var value string
+		if err := groups[1].Get(context.TODO(), key, StringSink(&value)); err != nil {
+			t.Fatal(err)
+		}
+		if value != want {
+			t.Errorf("Get(%q) after Set = %q, want %q", key, value, want)
+		}
+		if err := groups[0].Remove(context.TODO(), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Shrinking the pool keeps the connections to the remaining peers.
+	kept := pools[0].grpcGetters[addrs[1]]
+	if err := pools[0].Set(addrs[:2]...); err != nil {
+		t.Fatal(err)
+	}
+	if pools[0].grpcGetters[addrs[1]] != kept {
+		t.Error("Set replaced the connection to a peer still in the pool")
+	}
+	if _, ok := pools[0].grpcGetters[addrs[2]]; ok {
+		t.Error("Set kept a peer no longer in the pool")
+	}
+}
+
+func TestGRPCPoolReplication(t *testing.T) {
+	const self = "self.invalid:8000"
+	p := NewWorkspace().NewGRPCPool(self, &GRPCPoolOptions{Replication: 2})
+	defer p.Close()
+	if err := p.Set(self, "a.invalid:8000", "b.invalid:8000"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range testKeys(100) {
+		owners := p.PickPeers(key)
+		if len(owners) != 2 || owners[0] == owners[1] {
+			t.Fatalf("PickPeers(%q) = %v; want 2 distinct owners", key, owners)
+		}
+		primary, ok := p.PickPeer(key)
+		if ok != (owners[0] != nil) || ok && primary != owners[0] {
+			t.Errorf("PickPeers(%q)[0] = %v; want PickPeer's %v", key, owners[0], primary)
+		}
+	}
+
+	if owners := NewWorkspace().NewGRPCPool(self, nil).PickPeers("x"); owners != nil {
+		t.Errorf("PickPeers without Replication = %v; want nil", owners)
+	}
+}
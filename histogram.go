@@ -0,0 +1,94 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the buckets of a Histogram,
+// in increasing order. Durations above the last bound fall in a final
+// bucket of their own.
+var latencyBuckets = [...]time.Duration{
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyBuckets returns the upper bounds of the buckets of a
+// Histogram, in increasing order. Durations above the last bound fall
+// in a final bucket of their own.
+func LatencyBuckets() []time.Duration {
+	return append([]time.Duration(nil), latencyBuckets[:]...)
+}
+
+// A Histogram counts durations in the buckets of LatencyBuckets. Its
+// methods may be called concurrently.
+type Histogram struct {
+	counts [len(latencyBuckets) + 1]atomic.Int64 // one per bucket, and one above the last
+	sum    atomic.Int64                          // of all durations, in nanoseconds
+}
+
+// Observe counts d.
+func (h *Histogram) Observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+// Snapshot returns the counts of h.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{Counts: make([]int64, len(h.counts))}
+	for i := range h.counts {
+		s.Counts[i] = h.counts[i].Load()
+		s.Count += s.Counts[i]
+	}
+	s.Sum = time.Duration(h.sum.Load())
+	return s
+}
+
+// A HistogramSnapshot holds the counts of a Histogram at one time.
+type HistogramSnapshot struct {
+	// Counts are the number of durations in each bucket, that is,
+	// at most LatencyBuckets()[i] and above the bound before it. The
+	// last count is of the durations above every bound.
+	Counts []int64
+
+	Count int64         // of all durations
+	Sum   time.Duration // of all durations
+}
+
+// Latencies are per-group histograms of how long loads take.
+type Latencies struct {
+	LocalLoads  Histogram // calls of the Getter, good or bad
+	PeerFetches Histogram // requests to peers for keys, including failed ones
+}
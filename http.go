@@ -23,20 +23,34 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/golang/groupcache/consistenthash"
-	pb "github.com/golang/groupcache/groupcachepb"
 	"github.com/golang/protobuf/proto"
+	"groupcache/consistenthash"
+	pb "groupcache/groupcachepb"
 )
 
 const defaultBasePath = "/_groupcache/"
 
 const defaultReplicas = 50
 
+const (
+	defaultMaxFailures   = 5
+	defaultEjectDuration = 10 * time.Second
+
+	// maxEjectBackoff bounds how many times EjectDuration a peer
+	// failing its probes stays ejected.
+	maxEjectBackoff = 32
+)
+
 // HTTPPool implements PeerPicker for a pool of HTTP peers.
 type HTTPPool struct {
+	// Stats are statistics on the pool.
+	Stats PoolStats // first, to be 8-byte aligned on 32-bit platforms
+
 	// Context optionally specifies a context for the server to use when it
 	// receives a request.
 	// If nil, the server uses the request's context
@@ -50,12 +64,61 @@ type HTTPPool struct {
 	// this peer's base URL, e.g. "https://example.net:8000"
 	self string
 
+	// ws is the workspace whose groups the pool serves.
+	ws *Workspace
+
 	// opts specifies the options.
 	opts HTTPPoolOptions
 
-	mu          sync.Mutex // guards peers and httpGetters
-	peers       *consistenthash.Map
+	mu          sync.Mutex // guards peers, httpGetters, all, weights, health and nextProbe
+	peers       consistenthash.Placement
 	httpGetters map[string]*httpGetter // keyed by e.g. "http://10.0.0.2:8008"
+	all         []string               // peers as passed to Set, ejected or not
+	weights     map[string]int         // of peers in all; nil means all 1
+	health      map[string]*peerHealth
+	nextProbe   time.Time // earliest end of an ejection; zero if none
+}
+
+// PoolStats are statistics on an HTTPPool.
+type PoolStats struct {
+	PeerFailures AtomicInt // requests to peers which failed
+	Ejections    AtomicInt // peers ejected from the pool
+	Probes       AtomicInt // ejected peers let back in to be probed
+}
+
+// PeerHealth is the health of a peer of an HTTPPool, as seen by this
+// process.
+type PeerHealth struct {
+	Peer string
+
+	// Ejected is whether the peer is out of the pool, because of
+	// failed requests, until EjectedUntil. Its keys are owned by
+	// other peers meanwhile.
+	Ejected      bool
+	EjectedUntil time.Time
+
+	// Probing is whether the peer is back in the pool after an
+	// ejection, to be ejected again if its next request fails.
+	Probing bool
+
+	ConsecutiveFailures int
+	Failures            int64
+	Successes           int64
+	Ejections           int64
+
+	// InFlight is the number of requests in flight from this
+	// process to the peer. It is always zero for this process.
+	InFlight int64
+
+	// Latency is the mean latency of requests to the peer, with
+	// recent requests weighing the most.
+	Latency time.Duration
+}
+
+// peerHealth is the health of a peer, guarded by the pool's mu.
+type peerHealth struct {
+	PeerHealth
+	backoff time.Duration // of the next ejection
 }
 
 // HTTPPoolOptions are the configurations of a HTTPPool.
@@ -71,6 +134,60 @@ type HTTPPoolOptions struct {
 	// HashFn specifies the hash function of the consistent hash.
 	// If blank, it defaults to crc32.ChecksumIEEE.
 	HashFn consistenthash.Hash
+
+	// MaxFailures specifies the number of consecutive failed
+	// requests to a peer after which it is ejected from the pool.
+	// If blank, it defaults to 5. If negative, peers are never
+	// ejected.
+	MaxFailures int
+
+	// EjectDuration specifies how long a peer stays ejected before
+	// it is let back in to be probed by the next request for one of
+	// its keys. Each failed probe doubles the duration, up to 32
+	// times EjectDuration.
+	// If blank, it defaults to 10 seconds.
+	EjectDuration time.Duration
+
+	// LoadFactor, if not zero, bounds the load of peers: a key whose
+	// owner has more than LoadFactor times the mean number of
+	// requests in flight goes to the next peer on the consistent
+	// hash instead. Only the requests this process sends are
+	// counted, so it never has any in flight to itself: its own keys
+	// always stay with it, and it may take keys of busy peers. It
+	// must be zero or at least 1.
+	LoadFactor float64
+
+	// Placement, if not nil, creates the placement of keys on peers,
+	// such as consistenthash.NewRendezvous(nil), instead of a
+	// consistent hash of Replicas replicas using HashFn. Placements
+	// without an AddWeighted method ignore the weights of
+	// SetWeighted, and those without a SetLoadBound method ignore
+	// LoadFactor. Peers are added in the order given to Set, followed
+	// by those given to AddPeers, which matters to consistenthash.Jump.
+	Placement func() consistenthash.Placement
+
+	// Replication specifies the number of peers owning each key,
+	// the first of them as its primary owner and the others as
+	// replicas; see ReplicaPicker. Replication needs a placement
+	// with a GetN method, as all of the consistenthash placements
+	// have.
+	// If blank, it defaults to 1.
+	Replication int
+}
+
+// weightedPlacement is a Placement which supports SetWeighted.
+type weightedPlacement interface {
+	AddWeighted(node string, weight int)
+}
+
+// replicatedPlacement is a Placement which supports Replication.
+type replicatedPlacement interface {
+	GetN(key string, n int) []string
+}
+
+// boundedPlacement is a Placement which supports LoadFactor.
+type boundedPlacement interface {
+	SetLoadBound(c float64, load func(node string) int64)
 }
 
 // NewHTTPPool initializes an HTTP pool of peers, and registers itself as a PeerPicker.
@@ -83,19 +200,26 @@ func NewHTTPPool(self string) *HTTPPool {
 	return p
 }
 
-var httpPoolMade bool
-
 // NewHTTPPoolOpts initializes an HTTP pool of peers with the given options.
 // Unlike NewHTTPPool, this function does not register the created pool as an HTTP handler.
 // The returned *HTTPPool implements http.Handler and must be registered using http.Handle.
 func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
-	if httpPoolMade {
+	return DefaultWorkspace.NewHTTPPoolOpts(self, o)
+}
+
+// NewHTTPPoolOpts initializes an HTTP pool of peers for the groups of
+// ws, and registers itself as the PeerPicker of ws.
+// See the package-level NewHTTPPoolOpts.
+func (ws *Workspace) NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.httpPool != nil {
 		panic("groupcache: NewHTTPPool must be called only once")
 	}
-	httpPoolMade = true
 
 	p := &HTTPPool{
 		self:        self,
+		ws:          ws,
 		httpGetters: make(map[string]*httpGetter),
 	}
 	if o != nil {
@@ -107,29 +231,320 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 	if p.opts.Replicas == 0 {
 		p.opts.Replicas = defaultReplicas
 	}
-	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	if p.opts.MaxFailures == 0 {
+		p.opts.MaxFailures = defaultMaxFailures
+	}
+	if p.opts.EjectDuration == 0 {
+		p.opts.EjectDuration = defaultEjectDuration
+	}
+	p.peers = p.newPlacement()
+	p.health = make(map[string]*peerHealth)
 
-	RegisterPeerPicker(func() PeerPicker { return p })
+	ws.RegisterPeerPicker(func() PeerPicker { return p })
+	ws.httpPool = p
 	return p
 }
 
+// pool returns the HTTPPool made by NewHTTPPoolOpts for ws, if any.
+func (ws *Workspace) pool() *HTTPPool {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return ws.httpPool
+}
+
 // Set updates the pool's list of peers.
 // Each peer value should be a valid base URL,
 // for example "http://example.net:8000".
+// The clients and the health of peers already in the pool are kept.
 func (p *HTTPPool) Set(peers ...string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
-	p.peers.Add(peers...)
-	p.httpGetters = make(map[string]*httpGetter, len(peers))
+	p.setLocked(peers, nil)
+}
+
+// SetWeighted updates the pool's list of peers to the keys of peers,
+// each owning a share of the keys in proportion to its weight. A peer
+// of weight 1 owns as many keys as a peer passed to Set.
+func (p *HTTPPool) SetWeighted(peers map[string]int) {
+	list := make([]string, 0, len(peers))
+	for peer, weight := range peers {
+		if weight <= 0 {
+			panic("groupcache: peer weight must be positive")
+		}
+		list = append(list, peer)
+	}
+	sort.Strings(list)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setLocked(list, peers)
+}
+
+func (p *HTTPPool) setLocked(peers []string, weights map[string]int) {
+	p.all = append([]string(nil), peers...)
+	p.weights = make(map[string]int, len(weights))
+	for peer, weight := range weights {
+		p.weights[peer] = weight
+	}
+	getters := make(map[string]*httpGetter, len(peers))
+	health := make(map[string]*peerHealth, len(peers))
+	for _, peer := range peers {
+		getters[peer] = p.httpGetters[peer]
+		if getters[peer] == nil {
+			getters[peer] = p.newGetter(peer)
+		}
+		health[peer] = p.health[peer]
+		if health[peer] == nil {
+			health[peer] = &peerHealth{PeerHealth: PeerHealth{Peer: peer}}
+		}
+	}
+	p.httpGetters = getters
+	p.health = health
+	p.rebuildLocked()
+}
+
+func (p *HTTPPool) newGetter(peer string) *httpGetter {
+	return &httpGetter{transport: p.Transport, baseURL: peer + p.opts.BasePath, pool: p, peer: peer}
+}
+
+// AddPeers adds peers to the pool. Unlike Set, it leaves the peers
+// already in the pool, and the keys they own, as they are, taking
+// only the keys the new peers now own from them. Peers already in
+// the pool are ignored.
+func (p *HTTPPool) AddPeers(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var added []string
 	for _, peer := range peers {
-		p.httpGetters[peer] = &httpGetter{transport: p.Transport, baseURL: peer + p.opts.BasePath}
+		if _, ok := p.httpGetters[peer]; ok {
+			continue
+		}
+		p.all = append(p.all, peer)
+		p.httpGetters[peer] = p.newGetter(peer)
+		p.health[peer] = &peerHealth{PeerHealth: PeerHealth{Peer: peer}}
+		added = append(added, peer)
+	}
+	if len(added) == 0 {
+		return
+	}
+	if !p.incrementalLocked() {
+		p.rebuildLocked()
+		return
+	}
+	p.peers.Add(added...)
+}
+
+// RemovePeers removes peers from the pool, moving only the keys they
+// owned to the other peers. Peers not in the pool are ignored.
+func (p *HTTPPool) RemovePeers(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	removed := make(map[string]bool, len(peers))
+	for _, peer := range peers {
+		if _, ok := p.httpGetters[peer]; !ok {
+			continue
+		}
+		removed[peer] = true
+		delete(p.httpGetters, peer)
+		delete(p.health, peer)
+		delete(p.weights, peer)
+	}
+	if len(removed) == 0 {
+		return
+	}
+	all := p.all[:0]
+	for _, peer := range p.all {
+		if !removed[peer] {
+			all = append(all, peer)
+		}
+	}
+	p.all = all
+	if !p.incrementalLocked() {
+		p.rebuildLocked()
+		return
+	}
+	for peer := range removed {
+		p.peers.Remove(peer)
+	}
+	p.updateNextProbeLocked()
+}
+
+// newPlacement returns an empty placement of keys on peers.
+func (p *HTTPPool) newPlacement() consistenthash.Placement {
+	if p.opts.Placement != nil {
+		return p.opts.Placement()
+	}
+	return consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+}
+
+// updateNextProbeLocked sets nextProbe to the earliest end of an
+// ejection.
+func (p *HTTPPool) updateNextProbeLocked() {
+	p.nextProbe = time.Time{}
+	for _, h := range p.health {
+		if h.Ejected && (p.nextProbe.IsZero() || h.EjectedUntil.Before(p.nextProbe)) {
+			p.nextProbe = h.EjectedUntil
+		}
+	}
+}
+
+// incrementalLocked reports whether peers may be added to and removed
+// from the placement one at a time. The ring and rendezvous hashing
+// place keys the same whatever the order of their changes, and only
+// the keys of the changed peers move. Other placements are rebuilt:
+// consistenthash.Jump depends on the order of its nodes, and Maglev
+// rebuilds its table on each change anyway.
+func (p *HTTPPool) incrementalLocked() bool {
+	switch p.peers.(type) {
+	case *consistenthash.Map, *consistenthash.Rendezvous:
+		return true
+	}
+	return false
+}
+
+// addToRingLocked adds peer to the placement, with its weight.
+func (p *HTTPPool) addToRingLocked(peer string) {
+	if w, ok := p.peers.(weightedPlacement); ok && p.weights[peer] > 0 {
+		w.AddWeighted(peer, p.weights[peer])
+	} else {
+		p.peers.Add(peer)
+	}
+}
+
+// rebuildLocked rebuilds the placement of the peers not ejected, in
+// the order of p.all, so that it places keys as a pool newly Set to
+// the same peers would, whatever the order in which peers were added,
+// removed, ejected and let back in.
+func (p *HTTPPool) rebuildLocked() {
+	p.peers = p.newPlacement()
+	if b, ok := p.peers.(boundedPlacement); ok && p.opts.LoadFactor != 0 {
+		b.SetLoadBound(p.opts.LoadFactor, p.inFlightLocked)
+	}
+	weighted, _ := p.peers.(weightedPlacement)
+	var unweighted []string
+	for _, peer := range p.all {
+		if p.health[peer].Ejected {
+			continue
+		}
+		if w := p.weights[peer]; w > 0 && weighted != nil {
+			weighted.AddWeighted(peer, w)
+		} else {
+			unweighted = append(unweighted, peer)
+		}
+	}
+	p.peers.Add(unweighted...)
+	p.updateNextProbeLocked()
+}
+
+// probeLocked lets the peers whose ejection ended back into the pool.
+func (p *HTTPPool) probeLocked(now time.Time) {
+	if p.nextProbe.IsZero() || now.Before(p.nextProbe) {
+		return
+	}
+	incremental := p.incrementalLocked()
+	for peer, h := range p.health {
+		if h.Ejected && !now.Before(h.EjectedUntil) {
+			h.Ejected = false
+			h.EjectedUntil = time.Time{}
+			h.Probing = true
+			p.Stats.Probes.Add(1)
+			if incremental {
+				p.addToRingLocked(peer)
+			}
+		}
+	}
+	if !incremental {
+		p.rebuildLocked()
+		return
+	}
+	p.updateNextProbeLocked()
+}
+
+// inFlightLocked returns the number of requests in flight to peer.
+func (p *HTTPPool) inFlightLocked(peer string) int64 {
+	if h := p.health[peer]; h != nil {
+		return h.InFlight
+	}
+	return 0
+}
+
+// begin records the start of a request to peer.
+func (p *HTTPPool) begin(peer string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h := p.health[peer]; h != nil {
+		h.InFlight++
+	}
+}
+
+// end records the end of a request begun with begin.
+func (p *HTTPPool) end(peer string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h := p.health[peer]; h != nil && h.InFlight > 0 {
+		h.InFlight--
 	}
 }
 
+// record records the outcome of a request to peer which took d.
+func (p *HTTPPool) record(peer string, err error, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := p.health[peer]
+	if h == nil {
+		return // no longer in the pool
+	}
+	if err == nil {
+		h.Successes++
+		h.ConsecutiveFailures = 0
+		h.Probing = false
+		h.backoff = 0
+		h.Latency = meanLatency(h.Latency, d)
+		return
+	}
+	p.Stats.PeerFailures.Add(1)
+	h.Failures++
+	h.ConsecutiveFailures++
+	if h.Ejected || p.opts.MaxFailures < 0 || (!h.Probing && h.ConsecutiveFailures < p.opts.MaxFailures) {
+		return
+	}
+	if h.backoff == 0 {
+		h.backoff = p.opts.EjectDuration
+	}
+	h.Ejected = true
+	h.EjectedUntil = time.Now().Add(h.backoff)
+	h.Probing = false
+	h.Ejections++
+	p.Stats.Ejections.Add(1)
+	if h.backoff < maxEjectBackoff*p.opts.EjectDuration {
+		h.backoff *= 2
+	}
+	if !p.incrementalLocked() {
+		p.rebuildLocked()
+		return
+	}
+	p.peers.Remove(peer)
+	if p.nextProbe.IsZero() || h.EjectedUntil.Before(p.nextProbe) {
+		p.nextProbe = h.EjectedUntil
+	}
+}
+
+// Health returns the health of the pool's peers, sorted by peer.
+func (p *HTTPPool) Health() []PeerHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.probeLocked(time.Now())
+	res := make([]PeerHealth, 0, len(p.health))
+	for _, h := range p.health {
+		res = append(res, h.PeerHealth)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Peer < res[j].Peer })
+	return res
+}
+
 func (p *HTTPPool) PickPeer(key string) (ProtoGetter, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.probeLocked(time.Now())
 	if p.peers.IsEmpty() {
 		return nil, false
 	}
@@ -139,6 +554,42 @@ func (p *HTTPPool) PickPeer(key string) (ProtoGetter, bool) {
 	return nil, false
 }
 
+// PickPeers returns the Replication owners of key, with nil standing
+// for this process, or nil if Replication is below 2.
+func (p *HTTPPool) PickPeers(key string) []ProtoGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, ok := p.peers.(replicatedPlacement)
+	if !ok || p.opts.Replication < 2 {
+		return nil
+	}
+	p.probeLocked(time.Now())
+	nodes := r.GetN(key, p.opts.Replication)
+	if len(nodes) == 0 {
+		return nil
+	}
+	owners := make([]ProtoGetter, len(nodes))
+	for i, peer := range nodes {
+		if peer != p.self {
+			owners[i] = p.httpGetters[peer]
+		}
+	}
+	return owners
+}
+
+// GetAll returns the peers in the pool other than this one.
+func (p *HTTPPool) GetAll() []ProtoGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := make([]ProtoGetter, 0, len(p.httpGetters))
+	for peer, getter := range p.httpGetters {
+		if peer != p.self {
+			res = append(res, getter)
+		}
+	}
+	return res
+}
+
 func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Parse request.
 	if !strings.HasPrefix(r.URL.Path, p.opts.BasePath) {
@@ -153,11 +604,28 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	key := parts[1]
 
 	// Fetch the value for this group/key.
-	group := GetGroup(groupName)
+	group := p.ws.GetGroup(groupName)
 	if group == nil {
 		http.Error(w, "no such group: "+groupName, http.StatusNotFound)
 		return
 	}
+
+	// Remove the key from this peer's caches.
+	if r.Method == http.MethodDelete {
+		group.localRemove(key)
+		return
+	}
+
+	// Store the value sent by a peer in this peer's mainCache.
+	if r.Method == http.MethodPut {
+		var req pb.SetRequest
+		if !readProto(w, r, &req) {
+			return
+		}
+		group.serveSet(key, &req)
+		return
+	}
+
 	var ctx context.Context
 	if p.Context != nil {
 		ctx = p.Context(r)
@@ -165,50 +633,143 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ctx = r.Context()
 	}
 
-	group.Stats.ServerRequests.Add(1)
-	var value []byte
-	err := group.Get(ctx, key, AllocatingByteSliceSink(&value))
+	// Get the keys of a GetMultiRequest, posted to the group's path.
+	if r.Method == http.MethodPost {
+		var req pb.GetMultiRequest
+		if !readProto(w, r, &req) {
+			return
+		}
+		res, err := group.serveGetMulti(ctx, req.Keys)
+		if err == errTooManyKeys {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeProto(w, res, http.StatusOK)
+		return
+	}
+
+	res, err := group.serveGet(ctx, key)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	status := http.StatusOK
+	if res.GetNotFound() {
+		status = http.StatusNotFound
+	} else if res.ErrorCode != nil {
+		status = http.StatusInternalServerError
+	}
+	writeProto(w, res, status)
+}
 
-	// Write the value to the response body as a proto message.
-	body, err := proto.Marshal(&pb.GetResponse{Value: value})
+// readProto decodes the body of r into m, replying with an error if
+// it can't.
+func readProto(w http.ResponseWriter, r *http.Request, m proto.Message) bool {
+	b := bufferPool.Get().(*bytes.Buffer)
+	b.Reset()
+	defer bufferPool.Put(b)
+	if _, err := io.Copy(b, r.Body); err != nil {
+		http.Error(w, "reading request body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err := proto.Unmarshal(b.Bytes(), m); err != nil {
+		http.Error(w, "decoding request body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeProto writes m to the response body as a proto message.
+func writeProto(w http.ResponseWriter, m proto.Message, status int) {
+	body, err := proto.Marshal(m)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(status)
 	w.Write(body)
 }
 
 type httpGetter struct {
 	transport func(context.Context) http.RoundTripper
 	baseURL   string
+
+	// pool, if not nil, records the health of peer.
+	pool *HTTPPool
+	peer string
+}
+
+func (h *httpGetter) String() string {
+	if h.peer != "" {
+		return h.peer
+	}
+	return h.baseURL
 }
 
 var bufferPool = sync.Pool{
 	New: func() interface{} { return new(bytes.Buffer) },
 }
 
-func (h *httpGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+func (h *httpGetter) makeRequest(ctx context.Context, method, group, key string, body io.Reader) (*http.Response, error) {
 	u := fmt.Sprintf(
 		"%v%v/%v",
 		h.baseURL,
-		url.QueryEscape(in.GetGroup()),
-		url.QueryEscape(in.GetKey()),
+		url.QueryEscape(group),
+		url.QueryEscape(key),
 	)
-	req, err := http.NewRequest("GET", u, nil)
+	req, err := http.NewRequest(method, u, body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req = req.WithContext(ctx)
 	tr := http.DefaultTransport
 	if h.transport != nil {
 		tr = h.transport(ctx)
 	}
+	if h.pool != nil {
+		h.pool.begin(h.peer)
+		defer h.pool.end(h.peer)
+	}
+	start := time.Now()
 	res, err := tr.RoundTrip(req)
+	if h.pool != nil && ctx.Err() == nil {
+		// A peer is failing when it can't be reached or it
+		// answers with an error of its own, rather than one of
+		// its Getter's.
+		herr := err
+		if err == nil && res.StatusCode >= 500 && res.Header.Get("Content-Type") != "application/x-protobuf" {
+			herr = fmt.Errorf("server returned: %v", res.Status)
+		}
+		h.pool.record(h.peer, herr, time.Since(start))
+	}
+	return res, err
+}
+
+func (h *httpGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	res, err := h.makeRequest(ctx, http.MethodGet, in.GetGroup(), in.GetKey(), nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// Errors of the owner's Getter come back with a GetResponse
+	// body describing them.
+	if res.StatusCode != http.StatusOK && res.Header.Get("Content-Type") != "application/x-protobuf" {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	return readResponse(res, out)
+}
+
+func (h *httpGetter) GetMulti(ctx context.Context, in *pb.GetMultiRequest, out *pb.GetMultiResponse) error {
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	res, err := h.makeRequest(ctx, http.MethodPost, in.GetGroup(), "", bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -216,10 +777,15 @@ func (h *httpGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResp
 	if res.StatusCode != http.StatusOK {
 		return fmt.Errorf("server returned: %v", res.Status)
 	}
+	return readResponse(res, out)
+}
+
+// readResponse decodes the body of res into out.
+func readResponse(res *http.Response, out proto.Message) error {
 	b := bufferPool.Get().(*bytes.Buffer)
 	b.Reset()
 	defer bufferPool.Put(b)
-	_, err = io.Copy(b, res.Body)
+	_, err := io.Copy(b, res.Body)
 	if err != nil {
 		return fmt.Errorf("reading response body: %v", err)
 	}
@@ -229,3 +795,31 @@ func (h *httpGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResp
 	}
 	return nil
 }
+
+func (h *httpGetter) Remove(ctx context.Context, in *pb.GetRequest) error {
+	res, err := h.makeRequest(ctx, http.MethodDelete, in.GetGroup(), in.GetKey(), nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	return nil
+}
+
+func (h *httpGetter) Set(ctx context.Context, in *pb.SetRequest) error {
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	res, err := h.makeRequest(ctx, http.MethodPut, in.GetGroup(), in.GetKey(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	return nil
+}
@@ -18,11 +18,13 @@ package groupcache
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"strconv"
@@ -30,6 +32,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"groupcache/consistenthash"
 )
 
 var (
@@ -101,6 +105,519 @@ func TestHTTPPool(t *testing.T) {
 		}
 		t.Logf("Get key=%q, value=%q (peer:key)", key, value)
 	}
+
+	for _, key := range testKeys(nGets) {
+		want := "set:" + key
+		if err := g.Set(context.TODO(), key, []byte(want), nil); err != nil {
+			t.Fatal(err)
+		}
+		var value string
+		if err := g.Get(context.TODO(), key, StringSink(&value)); err != nil {
+			t.Fatal(err)
+		}
+		if value != want {
+			t.Errorf("Get(%q) after Set = %q, want %q", key, value, want)
+		}
+	}
+
+	for _, key := range testKeys(nGets) {
+		if err := g.Remove(context.TODO(), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestHTTPPools starts n in-process peers, each with its own
+// workspace and HTTP server, and a group using the getter made by
+// newGetter for the peer's index.
+func newTestHTTPPools(t *testing.T, n int, o *GroupOptions, newGetter func(i int) Getter) []*Group {
+	var (
+		pools  = make([]*HTTPPool, n)
+		groups = make([]*Group, n)
+		urls   []string
+	)
+	for i := 0; i < n; i++ {
+		i := i
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pools[i].ServeHTTP(w, r)
+		}))
+		t.Cleanup(server.Close)
+		urls = append(urls, server.URL)
+	}
+	for i := range pools {
+		ws := NewWorkspace()
+		pools[i] = ws.NewHTTPPoolOpts(urls[i], nil)
+		pools[i].Set(urls...)
+		groups[i] = ws.NewGroupOpts(t.Name(), 1<<20, newGetter(i), o)
+	}
+	return groups
+}
+
+// TestHTTPPoolWorkspaces tests that pools in separate workspaces
+// can run side by side in one process.
+func TestHTTPPoolWorkspaces(t *testing.T) {
+	const nWorkspaces = 2
+	groups := newTestHTTPPools(t, nWorkspaces, nil, func(i int) Getter {
+		prefix := strconv.Itoa(i) + ":"
+		return GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			return dest.SetString(prefix + key)
+		})
+	})
+
+	owners := make(map[string]bool)
+	for _, key := range testKeys(20) {
+		var value string
+		if err := groups[0].Get(context.TODO(), key, StringSink(&value)); err != nil {
+			t.Fatal(err)
+		}
+		if suffix := ":" + key; !strings.HasSuffix(value, suffix) {
+			t.Errorf("Get(%q) = %q, want value ending in %q", key, value, suffix)
+		}
+		owners[strings.TrimSuffix(value, ":"+key)] = true
+	}
+	if len(owners) != nWorkspaces {
+		t.Errorf("keys loaded by %d workspaces, want %d", len(owners), nWorkspaces)
+	}
+}
+
+func TestHTTPPoolNegative(t *testing.T) {
+	const nPeers = 2
+	var fills AtomicInt
+	groups := newTestHTTPPools(t, nPeers, &GroupOptions{NegativeTTL: time.Hour}, func(int) Getter {
+		return GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			fills.Add(1)
+			return ErrNotFound
+		})
+	})
+	for _, key := range testKeys(10) {
+		for _, g := range groups {
+			var value string
+			if err := g.Get(context.TODO(), key, StringSink(&value)); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get(%q) = %v, want ErrNotFound", key, err)
+			}
+		}
+	}
+	if n := fills.Get(); n != 10 {
+		t.Errorf("got %d fills for 10 missing keys, want 10", n)
+	}
+	for _, g := range groups {
+		if n := g.Stats.PeerErrors.Get(); n != 0 {
+			t.Errorf("%d peer errors, want 0", n)
+		}
+	}
+}
+
+func TestHTTPPoolError(t *testing.T) {
+	const codeDenied ErrorCode = 7
+	groups := newTestHTTPPools(t, 2, nil, func(i int) Getter {
+		return GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			return &Error{Code: codeDenied, Message: strconv.Itoa(i) + ": denied " + key}
+		})
+	})
+	owners := make(map[string]bool)
+	for _, key := range testKeys(20) {
+		var value string
+		err := groups[0].Get(context.TODO(), key, StringSink(&value))
+		var e *Error
+		if !errors.As(err, &e) || e.Code != codeDenied {
+			t.Fatalf("Get(%q) = %v; want *Error with code %d", key, err, codeDenied)
+		}
+		owners[strings.SplitN(e.Message, ":", 2)[0]] = true
+	}
+	if len(owners) != 2 {
+		t.Errorf("errors from %d peers, want 2", len(owners))
+	}
+	if n := groups[0].Stats.PeerErrors.Get(); n != 0 {
+		t.Errorf("%d peer errors, want 0", n)
+	}
+}
+
+func TestHTTPPoolGetMulti(t *testing.T) {
+	const nPeers = 3
+	groups := newTestHTTPPools(t, nPeers, nil, func(i int) Getter {
+		return GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			if key == "missing" {
+				return ErrNotFound
+			}
+			return dest.SetString(strconv.Itoa(i) + ":" + key)
+		})
+	})
+	keys := append(testKeys(20), "missing")
+	values := make([]string, len(keys))
+	dests := make([]Sink, len(keys))
+	for i := range dests {
+		dests[i] = StringSink(&values[i])
+	}
+	err := groups[0].GetMulti(context.TODO(), keys, dests)
+	merr, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("GetMulti = %v; want MultiError", err)
+	}
+	owners := make(map[string]bool)
+	for i, key := range keys {
+		if key == "missing" {
+			if !errors.Is(merr[i], ErrNotFound) {
+				t.Errorf("error of %q = %v; want ErrNotFound", key, merr[i])
+			}
+			continue
+		}
+		if merr[i] != nil {
+			t.Errorf("error of %q = %v", key, merr[i])
+		}
+		if suffix := ":" + key; !strings.HasSuffix(values[i], suffix) {
+			t.Errorf("value of %q = %q; want value ending in %q", key, values[i], suffix)
+		}
+		owners[strings.TrimSuffix(values[i], ":"+key)] = true
+	}
+	if len(owners) != nPeers {
+		t.Errorf("keys loaded by %d peers, want %d", len(owners), nPeers)
+	}
+	if n := groups[0].Stats.PeerErrors.Get(); n != 0 {
+		t.Errorf("%d peer errors, want 0", n)
+	}
+}
+
+func TestHTTPPoolHealth(t *testing.T) {
+	// The peer fails while down is set.
+	var down AtomicInt
+	peerWs := NewWorkspace()
+	var peerPool *HTTPPool
+	peerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Get() != 0 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		peerPool.ServeHTTP(w, r)
+	}))
+	defer peerServer.Close()
+	const self = "http://self.invalid"
+	peerPool = peerWs.NewHTTPPoolOpts(peerServer.URL, nil)
+	peerPool.Set(self, peerServer.URL)
+	peerWs.NewGroup(t.Name(), 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("peer:" + key)
+	}))
+
+	ws := NewWorkspace()
+	const ejectDuration = 50 * time.Millisecond
+	p := ws.NewHTTPPoolOpts(self, &HTTPPoolOptions{MaxFailures: 2, EjectDuration: ejectDuration})
+	p.Set(self, peerServer.URL)
+	g := ws.NewGroup(t.Name(), 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("self:" + key)
+	}))
+	// getAll gets 50 keys not got before, returning how many of
+	// their values each owner loaded.
+	getAll := func() (owners map[string]int) {
+		owners = make(map[string]int)
+		for _, key := range testKeys(50) {
+			var value string
+			if err := g.Get(context.TODO(), key+strconv.Itoa(int(time.Now().UnixNano())), StringSink(&value)); err != nil {
+				t.Fatal(err)
+			}
+			owners[strings.SplitN(value, ":", 2)[0]]++
+		}
+		return owners
+	}
+	health := func() PeerHealth {
+		for _, h := range p.Health() {
+			if h.Peer == peerServer.URL {
+				return h
+			}
+		}
+		t.Fatal("peer missing from Health")
+		return PeerHealth{}
+	}
+
+	if owners := getAll(); owners["peer"] == 0 {
+		t.Fatalf("owners = %v; want some keys owned by the peer", owners)
+	}
+
+	// A failing peer is ejected, and its keys loaded locally.
+	down.Add(1)
+	if owners := getAll(); owners["peer"] != 0 {
+		t.Fatalf("owners = %v while the peer is down", owners)
+	}
+	if h := health(); !h.Ejected || h.Failures != 2 || h.Ejections != 1 {
+		t.Errorf("health = %+v; want ejected after 2 failures", h)
+	}
+	if n := g.Stats.PeerErrors.Get(); n != 2 {
+		t.Errorf("PeerErrors = %d; want 2", n)
+	}
+
+	// A failed probe ejects it again, for longer.
+	time.Sleep(ejectDuration)
+	getAll()
+	h := health()
+	if !h.Ejected || h.Failures != 3 || h.Ejections != 2 {
+		t.Errorf("health = %+v; want ejected again after a failed probe", h)
+	}
+	if d := time.Until(h.EjectedUntil); d <= ejectDuration {
+		t.Errorf("ejected for %v after a failed probe; want more than %v", d, ejectDuration)
+	}
+
+	// A successful probe lets it back in.
+	down.Add(-1)
+	time.Sleep(time.Until(h.EjectedUntil))
+	if owners := getAll(); owners["peer"] == 0 {
+		t.Errorf("owners = %v; want some keys owned by the peer after it is back", owners)
+	}
+	if h := health(); h.Ejected || h.Probing || h.ConsecutiveFailures != 0 {
+		t.Errorf("health = %+v; want healthy", h)
+	}
+	if n := p.Stats.Probes.Get(); n != 2 {
+		t.Errorf("Probes = %d; want 2", n)
+	}
+}
+
+func TestHTTPPoolLoadFactor(t *testing.T) {
+	peers := []string{"http://a.invalid", "http://b.invalid", "http://c.invalid"}
+	p := NewWorkspace().NewHTTPPoolOpts(peers[0], &HTTPPoolOptions{LoadFactor: 1.25})
+	p.Set(peers...)
+	owner := func(key string) string {
+		if g, ok := p.PickPeer(key); ok {
+			return g.(*httpGetter).peer
+		}
+		return p.self
+	}
+	key := "key"
+	for owner(key) == p.self {
+		key += "+"
+	}
+	busy := owner(key)
+
+	// Requests in flight to the owner push its keys to other peers,
+	// until they end.
+	for i := 0; i < 3; i++ {
+		p.begin(busy)
+	}
+	if got := owner(key); got == busy {
+		t.Errorf("PickPeer(%q) = %s with 3 requests in flight to it", key, got)
+	}
+	for _, h := range p.Health() {
+		var want int64
+		if h.Peer == busy {
+			want = 3
+		}
+		if h.InFlight != want {
+			t.Errorf("%s has %d requests in flight; want %d", h.Peer, h.InFlight, want)
+		}
+	}
+
+	// This process has no requests in flight to itself, so it takes
+	// the keys of peers that are all busy.
+	for _, peer := range peers[1:] {
+		if peer != busy {
+			for i := 0; i < 3; i++ {
+				p.begin(peer)
+			}
+		}
+	}
+	if got := owner(key); got != p.self {
+		t.Errorf("PickPeer(%q) = %s with every other peer busy; want %s", key, got, p.self)
+	}
+	for i := 0; i < 3; i++ {
+		p.end(busy)
+	}
+	if got := owner(key); got != busy {
+		t.Errorf("PickPeer(%q) = %s after requests ended; want %s", key, got, busy)
+	}
+}
+
+func TestHTTPPoolWeighted(t *testing.T) {
+	peers := map[string]int{"http://a.invalid": 1, "http://b.invalid": 3}
+	p := NewWorkspace().NewHTTPPoolOpts("http://self.invalid", nil)
+	p.SetWeighted(peers)
+	counts := make(map[string]int)
+	const n = 10000
+	for _, key := range testKeys(n) {
+		g, ok := p.PickPeer(key)
+		if !ok {
+			t.Fatalf("PickPeer(%q) picked no peer", key)
+		}
+		counts[g.(*httpGetter).peer]++
+	}
+	for peer, weight := range peers {
+		want := n * weight / 4
+		if got := counts[peer]; got < want*8/10 || got > want*12/10 {
+			t.Errorf("%s of weight %d owns %d of %d keys; want about %d", peer, weight, got, n, want)
+		}
+	}
+}
+
+func TestHTTPPoolPlacement(t *testing.T) {
+	placements := map[string]func() consistenthash.Placement{
+		"rendezvous": func() consistenthash.Placement { return consistenthash.NewRendezvous(nil) },
+		"jump":       func() consistenthash.Placement { return consistenthash.NewJump(nil) },
+		"maglev":     func() consistenthash.Placement { return consistenthash.NewMaglev(0, nil) },
+	}
+	peers := []string{"http://a.invalid", "http://b.invalid", "http://c.invalid"}
+	for name, placement := range placements {
+		p1 := NewWorkspace().NewHTTPPoolOpts("http://self.invalid", &HTTPPoolOptions{Placement: placement})
+		p2 := NewWorkspace().NewHTTPPoolOpts("http://other.invalid", &HTTPPoolOptions{Placement: placement})
+		p1.Set(peers...)
+		p2.Set(peers...)
+		counts := make(map[string]int)
+		for _, key := range testKeys(3000) {
+			g1, ok1 := p1.PickPeer(key)
+			g2, ok2 := p2.PickPeer(key)
+			if !ok1 || !ok2 || g1.(*httpGetter).peer != g2.(*httpGetter).peer {
+				t.Fatalf("%s: pools disagree on the owner of %q", name, key)
+			}
+			counts[g1.(*httpGetter).peer]++
+		}
+		for _, peer := range peers {
+			if counts[peer] < 800 {
+				t.Errorf("%s: %s owns %d of 3000 keys", name, peer, counts[peer])
+			}
+		}
+	}
+}
+
+// TestHTTPPoolRebuild tests that changing the peers of a pool places
+// keys as a pool newly Set to the same peers does, changing the ring
+// and rendezvous placements in place and rebuilding the others.
+func TestHTTPPoolRebuild(t *testing.T) {
+	const self = "http://self.invalid"
+	placements := map[string]func() consistenthash.Placement{
+		"ring":       nil,
+		"rendezvous": func() consistenthash.Placement { return consistenthash.NewRendezvous(nil) },
+		"jump":       func() consistenthash.Placement { return consistenthash.NewJump(nil) },
+		"maglev":     func() consistenthash.Placement { return consistenthash.NewMaglev(0, nil) },
+	}
+	for name, placement := range placements {
+		opts := &HTTPPoolOptions{Placement: placement, MaxFailures: 1}
+		p := NewWorkspace().NewHTTPPoolOpts(self, opts)
+		p.Set(self, "http://a.invalid", "http://b.invalid", "http://c.invalid")
+		placement := p.peers
+		p.AddPeers("http://d.invalid")
+
+		// Eject b and probe it back.
+		p.record("http://b.invalid", errors.New("failed"), 0)
+		p.mu.Lock()
+		p.probeLocked(time.Now().Add(time.Hour))
+		p.mu.Unlock()
+		check := func(peers ...string) {
+			fresh := NewWorkspace().NewHTTPPoolOpts(self, opts)
+			fresh.Set(peers...)
+			for _, key := range testKeys(1000) {
+				got, want := self, self
+				if g, ok := p.PickPeer(key); ok {
+					got = g.(*httpGetter).peer
+				}
+				if g, ok := fresh.PickPeer(key); ok {
+					want = g.(*httpGetter).peer
+				}
+				if got != want {
+					t.Fatalf("%s: PickPeer(%q) = %s; a new pool of %q picks %s", name, key, got, peers, want)
+				}
+			}
+		}
+		check(self, "http://a.invalid", "http://b.invalid", "http://c.invalid", "http://d.invalid")
+
+		p.RemovePeers("http://a.invalid")
+		check(self, "http://b.invalid", "http://c.invalid", "http://d.invalid")
+
+		incremental := name == "ring" || name == "rendezvous"
+		if inPlace := p.peers == placement; inPlace != incremental {
+			t.Errorf("%s: placement changed in place = %v; want %v", name, inPlace, incremental)
+		}
+	}
+}
+
+func TestHTTPPoolReplication(t *testing.T) {
+	const self = "http://self.invalid"
+	p := NewWorkspace().NewHTTPPoolOpts(self, &HTTPPoolOptions{Replication: 2})
+	p.Set(self, "http://a.invalid", "http://b.invalid")
+	for _, key := range testKeys(100) {
+		owners := p.PickPeers(key)
+		if len(owners) != 2 || owners[0] == owners[1] {
+			t.Fatalf("PickPeers(%q) = %v; want 2 distinct owners", key, owners)
+		}
+		primary, ok := p.PickPeer(key)
+		if ok != (owners[0] != nil) || ok && primary != owners[0] {
+			t.Errorf("PickPeers(%q)[0] = %v; want PickPeer's %v", key, owners[0], primary)
+		}
+	}
+
+	// An ejected peer is no longer an owner.
+	for i := 0; i < defaultMaxFailures; i++ {
+		p.record("http://a.invalid", errors.New("failed"), 0)
+	}
+	for _, key := range testKeys(100) {
+		for _, owner := range p.PickPeers(key) {
+			if owner != nil && owner.(*httpGetter).peer == "http://a.invalid" {
+				t.Fatalf("PickPeers(%q) picked the ejected peer", key)
+			}
+		}
+	}
+
+	if owners := NewWorkspace().NewHTTPPoolOpts(self, nil).PickPeers("x"); owners != nil {
+		t.Errorf("PickPeers without Replication = %v; want nil", owners)
+	}
+}
+
+func TestHTTPPoolAddRemovePeers(t *testing.T) {
+	const self = "http://self.invalid"
+	p := NewWorkspace().NewHTTPPoolOpts(self, nil)
+	p.Set(self, "http://a.invalid", "http://b.invalid")
+	owners := func() map[string]string {
+		owners := make(map[string]string)
+		for _, key := range testKeys(1000) {
+			owners[key] = self
+			if g, ok := p.PickPeer(key); ok {
+				owners[key] = g.(*httpGetter).peer
+			}
+		}
+		return owners
+	}
+	getterA := p.httpGetters["http://a.invalid"]
+	p.record("http://a.invalid", errors.New("failed"), 0)
+
+	before := owners()
+	p.AddPeers("http://c.invalid", "http://a.invalid")
+	after := owners()
+	var moved int
+	for key, owner := range after {
+		if owner != before[key] {
+			moved++
+			if owner != "http://c.invalid" {
+				t.Fatalf("key %q moved from %s to %s; want only moves to the new peer", key, before[key], owner)
+			}
+		}
+	}
+	if moved == 0 {
+		t.Error("no keys moved to the new peer")
+	}
+	if p.httpGetters["http://a.invalid"] != getterA {
+		t.Error("AddPeers replaced the client of a peer in the pool")
+	}
+	if n := len(p.GetAll()); n != 3 {
+		t.Errorf("GetAll returned %d peers; want 3", n)
+	}
+
+	before = after
+	p.RemovePeers("http://b.invalid", "http://d.invalid")
+	for key, owner := range owners() {
+		if owner == "http://b.invalid" {
+			t.Fatalf("key %q owned by removed peer", key)
+		}
+		if before[key] != "http://b.invalid" && owner != before[key] {
+			t.Errorf("key %q moved from %s to %s", key, before[key], owner)
+		}
+	}
+	for _, h := range p.Health() {
+		if h.Peer == "http://a.invalid" && h.Failures != 1 {
+			t.Errorf("health of a = %+v; want its failure kept", h)
+		}
+		if h.Peer == "http://b.invalid" {
+			t.Error("removed peer still has a health")
+		}
+	}
+
+	// Set keeps the clients of peers staying in the pool.
+	p.Set(self, "http://a.invalid")
+	if p.httpGetters["http://a.invalid"] != getterA {
+		t.Error("Set replaced the client of a peer in the pool")
+	}
 }
 
 func testKeys(n int) (keys []string) {
@@ -165,3 +682,86 @@ func awaitAddrReady(t *testing.T, addr string, wg *sync.WaitGroup) {
 		time.Sleep(delay)
 	}
 }
+
+func TestDebugHandler(t *testing.T) {
+	// The peer is down, so that its keys are loaded locally after a
+	// peer error.
+	peerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer peerServer.Close()
+	const self = "http://self.invalid"
+	ws := NewWorkspace()
+	p := ws.NewHTTPPoolOpts(self, &HTTPPoolOptions{MaxFailures: -1})
+	p.Set(self, peerServer.URL)
+	g := ws.NewGroup("<group>", 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(key)
+	}))
+	for i := 0; i < 3; i++ {
+		for _, key := range testKeys(20) {
+			var value string
+			if err := g.Get(context.TODO(), key, StringSink(&value)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var value string
+	for i := 0; i < 5; i++ {
+		g.Get(context.TODO(), "hot", StringSink(&value))
+	}
+
+	rec := httptest.NewRecorder()
+	ws.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", DebugPath+"?format=json&top=2", nil))
+	var page debugPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	if page.Self != self || len(page.Peers) != 2 {
+		t.Errorf("self, peers = %q, %v; want %q and 2 peers", page.Self, page.Peers, self)
+	}
+	if len(page.Groups) != 1 {
+		t.Fatalf("got %d groups; want 1", len(page.Groups))
+	}
+	pg := page.Groups[0]
+	if pg.Name != "<group>" || pg.Stats["Gets"] != g.Stats.Gets.Get() || pg.MainCache.Items == 0 {
+		t.Errorf("group = %+v; want <group> with its stats", pg)
+	}
+	if len(pg.TopKeys) != 2 || pg.TopKeys[0].Key != "hot" {
+		t.Errorf("top keys = %v; want hot first of 2", pg.TopKeys)
+	}
+	if len(pg.PeerErrors) == 0 || pg.PeerErrors[0].Peer != peerServer.URL {
+		t.Errorf("peer errors = %v; want errors of %s", pg.PeerErrors, peerServer.URL)
+	}
+	if n := len(pg.PeerErrors); n > peerErrorLogSize {
+		t.Errorf("got %d peer errors; want at most %d", n, peerErrorLogSize)
+	}
+
+	rec = httptest.NewRecorder()
+	ws.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", DebugPath, nil))
+	body := rec.Body.String()
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q; want text/html", ct)
+	}
+	for _, want := range []string{"Group &lt;group&gt;", peerServer.URL, "LocalLoads"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page lacks %q", want)
+		}
+	}
+}
+
+// The debug page may be served while the HTTPPool is being made; run
+// with -race.
+func TestDebugHandlerNewHTTPPool(t *testing.T) {
+	ws := NewWorkspace()
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		rec := httptest.NewRecorder()
+		ws.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", DebugPath+"?format=json", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d; want 200", rec.Code)
+		}
+	}()
+	ws.NewHTTPPoolOpts("http://self.invalid", nil)
+	<-done
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lfu implements an LFU cache.
+// lfu包实现LFU(Least Frequently Used 最不经常使用)缓存算法
+package lfu
+
+import "container/list"
+
+// Cache is an LFU cache of values of type V by keys of type K. It is
+// not safe for concurrent access.
+// Entries used equally often are evicted in least recently used order.
+// Cache结构体是LFU cache算法，并发访问不安全；使用次数相同时淘汰最久未使用的记录
+type Cache[K comparable, V any] struct {
+	// MaxEntries is the maximum number of cache entries before
+	// an item is evicted. Zero means no limit.
+	// 最大缓存数量，0代表无限制
+	MaxEntries int
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	// 缓存实体被清除时回调函数
+	OnEvicted func(key K, value V)
+
+	// 每个使用次数对应一个双向链表，链表头部为最近使用的记录
+	freqs map[int]*list.List
+	// map key为K类型 value为链表节点指针
+	cache map[K]*list.Element
+	// 最小使用次数，可能已过期，见minFreqList
+	minFreq int
+}
+
+// A Key may be any value that is comparable. See http://golang.org/ref/spec#Comparison_operators
+// 任何可比较的类型
+type Key interface{}
+
+// AnyCache is a Cache of keys and values of any type, as Cache was
+// before it had type parameters.
+// AnyCache 键和值为任意类型的Cache，兼容泛型之前的Cache
+type AnyCache = Cache[Key, interface{}]
+
+// 记录结构体
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+	freq  int
+}
+
+// New creates a new AnyCache.
+// If maxEntries is zero, the cache has no limit and it's assumed
+// that eviction is done by the caller.
+// New 创建新的缓存实例
+// 如果 maxEntries为零，则缓存没有限制，淘汰缓存由调用者完成
+func New(maxEntries int) *AnyCache {
+	return NewCache[Key, interface{}](maxEntries)
+}
+
+// NewCache creates a new Cache of values of type V by keys of type K.
+// If maxEntries is zero, the cache has no limit.
+// NewCache 创建新的泛型缓存实例
+func NewCache[K comparable, V any](maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{
+		MaxEntries: maxEntries,
+		freqs:      make(map[int]*list.List),
+		cache:      make(map[K]*list.Element),
+	}
+}
+
+// Add adds a value to the cache, counting as a use of the key.
+// Add 往缓存中添加一个值，并计为一次使用
+func (c *Cache[K, V]) Add(key K, value V) {
+	if c.cache == nil {
+		c.cache = make(map[K]*list.Element)
+		c.freqs = make(map[int]*list.List)
+	}
+	if ele, ok := c.cache[key]; ok {
+		ele.Value.(*entry[K, V]).value = value
+		c.touch(ele)
+		return
+	}
+	// 先淘汰，避免新记录本身被淘汰
+	if c.MaxEntries != 0 && len(c.cache) >= c.MaxEntries {
+		c.RemoveOldest()
+	}
+	c.cache[key] = c.list(1).PushFront(&entry[K, V]{key, value, 1})
+	c.minFreq = 1
+}
+
+// Get looks up a key's value from the cache.
+// Get 根据key查找value，并增加使用次数
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		c.touch(ele)
+		return ele.Value.(*entry[K, V]).value, true
+	}
+	return
+}
+
+// Remove removes the provided key from the cache.
+// Remove 移除指定key的缓存记录
+func (c *Cache[K, V]) Remove(key K) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveOldest removes the least frequently used item from the cache.
+// RemoveOldest 移除使用次数最少的缓存记录
+func (c *Cache[K, V]) RemoveOldest() {
+	if len(c.cache) == 0 {
+		return
+	}
+	c.removeElement(c.minFreqList().Back())
+}
+
+// Peek returns the value of key without counting as a use of it.
+// Peek 根据key查找value，不计为一次使用
+func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
+	if ele, hit := c.cache[key]; hit {
+		return ele.Value.(*entry[K, V]).value, true
+	}
+	return
+}
+
+// Len returns the number of items in the cache.
+// 返回缓存记录数目
+func (c *Cache[K, V]) Len() int {
+	return len(c.cache)
+}
+
+// Clear purges all stored items from the cache.
+// 删除缓存所有记录
+func (c *Cache[K, V]) Clear() {
+	if c.OnEvicted != nil {
+		for _, e := range c.cache {
+			kv := e.Value.(*entry[K, V])
+			c.OnEvicted(kv.key, kv.value)
+		}
+	}
+	c.freqs = nil
+	c.cache = nil
+	c.minFreq = 0
+}
+
+// touch moves ele to the list of the next use count.
+// 将记录移动到使用次数加一的链表头部
+func (c *Cache[K, V]) touch(ele *list.Element) {
+	kv := ele.Value.(*entry[K, V])
+	c.unlink(ele)
+	if kv.freq == c.minFreq && c.freqs[kv.freq] == nil {
+		c.minFreq++
+	}
+	kv.freq++
+	c.cache[kv.key] = c.list(kv.freq).PushFront(kv)
+}
+
+// list returns the list of entries used freq times, creating it if needed.
+func (c *Cache[K, V]) list(freq int) *list.List {
+	l := c.freqs[freq]
+	if l == nil {
+		l = list.New()
+		c.freqs[freq] = l
+	}
+	return l
+}
+
+// minFreqList returns the non-empty list of the least used entries.
+// Removals may leave minFreq pointing at no list, in which case it is
+// recomputed.
+func (c *Cache[K, V]) minFreqList() *list.List {
+	if l := c.freqs[c.minFreq]; l != nil {
+		return l
+	}
+	c.minFreq = 0
+	for freq := range c.freqs {
+		if c.minFreq == 0 || freq < c.minFreq {
+			c.minFreq = freq
+		}
+	}
+	return c.freqs[c.minFreq]
+}
+
+// unlink removes ele from its list, dropping the list once empty.
+func (c *Cache[K, V]) unlink(ele *list.Element) {
+	freq := ele.Value.(*entry[K, V]).freq
+	l := c.freqs[freq]
+	l.Remove(ele)
+	if l.Len() == 0 {
+		delete(c.freqs, freq)
+	}
+}
+
+// 移除记录节点
+func (c *Cache[K, V]) removeElement(e *list.Element) {
+	c.unlink(e)
+	kv := e.Value.(*entry[K, V])
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lfu
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	lfu := New(0)
+	lfu.Add("myKey", 1234)
+	if val, ok := lfu.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("Get(myKey) = %v, %v; want 1234, true", val, ok)
+	}
+	if _, ok := lfu.Get("nonsense"); ok {
+		t.Fatal("Get(nonsense) hit")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	lfu := New(0)
+	lfu.Add("myKey", 1234)
+	lfu.Get("myKey")
+	lfu.Add("other", 1)
+	lfu.Remove("myKey")
+	if _, ok := lfu.Get("myKey"); ok {
+		t.Fatal("TestRemove returned a removed entry")
+	}
+	if lfu.Len() != 1 {
+		t.Fatalf("Len = %d; want 1", lfu.Len())
+	}
+	lfu.Remove("other")
+	lfu.RemoveOldest() // must not panic on an empty cache
+}
+
+func TestEvict(t *testing.T) {
+	evictedKeys := make([]Key, 0)
+	onEvictedFun := func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+
+	lfu := New(20)
+	lfu.OnEvicted = onEvictedFun
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("myKey%d", i)
+		lfu.Add(key, 1234)
+		if i%2 == 0 {
+			lfu.Get(key)
+		}
+	}
+	for i := 20; i < 22; i++ {
+		lfu.Add(fmt.Sprintf("myKey%d", i), 1234)
+	}
+
+	// The least used keys go first, least recently used among them.
+	if len(evictedKeys) != 2 {
+		t.Fatalf("got %d evicted keys; want 2", len(evictedKeys))
+	}
+	if evictedKeys[0] != Key("myKey1") {
+		t.Fatalf("got %v in first evicted key; want %s", evictedKeys[0], "myKey1")
+	}
+	if evictedKeys[1] != Key("myKey3") {
+		t.Fatalf("got %v in second evicted key; want %s", evictedKeys[1], "myKey3")
+	}
+}
+
+func TestTyped(t *testing.T) {
+	var evicted []string
+	c := NewCache[string, int](2)
+	c.OnEvicted = func(key string, value int) {
+		evicted = append(evicted, key)
+	}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("b")
+	// Peek is not a use, so "a" stays the least used key.
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Fatalf("Peek(a) = %v, %v; want 1, true", v, ok)
+	}
+	c.Add("c", 3)
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Fatalf("evicted %v; want [a]", evicted)
+	}
+}
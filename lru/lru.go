@@ -18,146 +18,264 @@ limitations under the License.
 //lru包实现LRU(Least Recently Used 最近最少使用)缓存算法
 package lru
 
-import "container/list"
-
-// Cache is an LRU cache. It is not safe for concurrent access.
+// Cache is an LRU cache of values of type V by keys of type K. It is
+// not safe for concurrent access. The zero value is an empty cache
+// with no limit.
 // Cache结构体是LRU cache算法，并发访问不安全
-type Cache struct {
+type Cache[K comparable, V any] struct {
 	// MaxEntries is the maximum number of cache entries before
 	// an item is evicted. Zero means no limit.
 	// 最大缓存数量，0代表无限制
 	MaxEntries int
 
+	// MaxCost is the maximum total cost of the cache entries, as
+	// given by Cost, before the oldest ones are evicted. Zero means
+	// no limit.
+	// 缓存记录的最大总开销，0代表无限制
+	MaxCost int64
+
+	// Cost optionally specifies the cost of an entry, such as its
+	// size in bytes. If nil, every entry costs 1.
+	// 计算缓存记录开销的函数，为nil时每条记录开销为1
+	Cost func(key K, value V) int64
+
 	// OnEvicted optionally specifies a callback function to be
 	// executed when an entry is purged from the cache.
 	// 缓存实体被清除时回调函数
-	OnEvicted func(key Key, value interface{})
+	OnEvicted func(key K, value V)
 
-	// 双向链表
-	ll    *list.List
-	// map key为任意类型 value为链表节点指针
-	cache map[interface{}]*list.Element
+	// 双向链表的哨兵节点，root.next为最新记录，root.prev为最旧记录
+	root entry[K, V]
+	// 所有记录的总开销
+	cost int64
+	// map key为K类型 value为链表节点指针
+	cache map[K]*entry[K, V]
 }
 
 // A Key may be any value that is comparable. See http://golang.org/ref/spec#Comparison_operators
 // 任何可比较的类型
 type Key interface{}
 
-// 记录结构体
-type entry struct {
-	key   Key
-	value interface{}
+// AnyCache is a Cache of keys and values of any type, as Cache was
+// before it had type parameters.
+// AnyCache 键和值为任意类型的Cache，兼容泛型之前的Cache
+type AnyCache = Cache[Key, interface{}]
+
+// 记录结构体，同时是双向链表的节点
+type entry[K comparable, V any] struct {
+	prev, next *entry[K, V]
+	key        K
+	value      V
+	cost       int64
 }
 
-// New creates a new Cache.
+// New creates a new AnyCache.
 // If maxEntries is zero, the cache has no limit and it's assumed
 // that eviction is done by the caller.
 // New 创建新的缓存实例
 // 如果 maxEntries为零，则缓存没有限制，淘汰缓存由调用者完成
-func New(maxEntries int) *Cache {
-	return &Cache{
-		MaxEntries: maxEntries,
-		ll:         list.New(),
-		cache:      make(map[interface{}]*list.Element),
-	}
+func New(maxEntries int) *AnyCache {
+	return NewCache[Key, interface{}](maxEntries)
 }
 
-// Add adds a value to the cache.
-// Add 往缓存中添加一个值
-func (c *Cache) Add(key Key, value interface{}) {
+// NewCache creates a new Cache of values of type V by keys of type K.
+// If maxEntries is zero, the cache has no limit.
+// NewCache 创建新的泛型缓存实例
+func NewCache[K comparable, V any](maxEntries int) *Cache[K, V] {
+	c := &Cache[K, V]{MaxEntries: maxEntries}
+	c.init()
+	return c
+}
+
+func (c *Cache[K, V]) init() {
+	c.root.next = &c.root
+	c.root.prev = &c.root
+	c.cache = make(map[K]*entry[K, V])
+}
+
+// Add adds a value to the cache, then evicts the oldest entries
+// until the cache is within MaxEntries and MaxCost. An entry costing
+// more than MaxCost on its own is evicted too.
+// Add 往缓存中添加一个值，然后淘汰最旧的记录，直到记录数和总开销都不超过上限
+func (c *Cache[K, V]) Add(key K, value V) {
 	// 如果缓存没有初始化，则先初始化
 	if c.cache == nil {
-		c.cache = make(map[interface{}]*list.Element)
-		c.ll = list.New()
+		c.init()
 	}
+	cost := c.costOf(key, value)
 	// 如果key已经存在，则将记录移动到链表的头部，然后设置value
-	if ee, ok := c.cache[key]; ok {
-		c.ll.MoveToFront(ee)
-		ee.Value.(*entry).value = value
-		return
+	if e, ok := c.cache[key]; ok {
+		c.moveToFront(e)
+		e.value = value
+		c.cost += cost - e.cost
+		e.cost = cost
+	} else {
+		// 如果key不存在,创建记录，并将记录插入到链表的头部
+		e := &entry[K, V]{key: key, value: value, cost: cost}
+		c.pushFront(e)
+		c.cache[key] = e
+		c.cost += cost
+	}
+	// 超过最大数量或最大开销，触发清理最旧记录
+	c.evict()
+}
+
+func (c *Cache[K, V]) costOf(key K, value V) int64 {
+	if c.Cost == nil {
+		return 1
 	}
-	// 如果key不存在,创建记录，并将记录移动到链表的头部,ele为链表节点指针
-	ele := c.ll.PushFront(&entry{key, value})
-	c.cache[key] = ele
-	// 缓存最大数量，超过触发清理最旧记录
-	if c.MaxEntries != 0 && c.ll.Len() > c.MaxEntries {
+	return c.Cost(key, value)
+}
+
+// evict removes the oldest entries until the cache is within its
+// limits, and returns how many it removed.
+func (c *Cache[K, V]) evict() (n int) {
+	for len(c.cache) > 0 &&
+		(c.MaxEntries != 0 && len(c.cache) > c.MaxEntries ||
+			c.MaxCost != 0 && c.cost > c.MaxCost) {
 		c.RemoveOldest()
+		n++
 	}
+	return n
 }
 
 // Get looks up a key's value from the cache.
 // Get 根据key查找value
-func (c *Cache) Get(key Key) (value interface{}, ok bool) {
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
 	if c.cache == nil {
 		return
 	}
 	// 如果key存在
-	if ele, hit := c.cache[key]; hit {
+	if e, hit := c.cache[key]; hit {
 		// 将记录移动至链表头部
-		c.ll.MoveToFront(ele)
+		c.moveToFront(e)
 		// 返回记录的值
-		return ele.Value.(*entry).value, true
+		return e.value, true
 	}
 	return
 }
 
+// Peek returns a key's value from the cache, without counting as a
+// use of it.
+// Peek 根据key查找value，不移动记录
+func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
+	if e, hit := c.cache[key]; hit {
+		return e.value, true
+	}
+	return
+}
+
+// Contains reports whether key is in the cache, without counting as
+// a use of it.
+// Contains 判断key是否在缓存中，不移动记录
+func (c *Cache[K, V]) Contains(key K) bool {
+	_, ok := c.cache[key]
+	return ok
+}
+
+// Keys returns the keys in the cache, from the oldest to the newest.
+// Keys 返回缓存中所有key，从最旧到最新
+func (c *Cache[K, V]) Keys() []K {
+	if c.cache == nil {
+		return nil
+	}
+	keys := make([]K, 0, len(c.cache))
+	for e := c.root.prev; e != &c.root; e = e.prev {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+// Resize sets MaxEntries and MaxCost, evicting the oldest entries
+// until the cache is within them, and returns the number of entries
+// evicted.
+// Resize 设置最大数量和最大开销，淘汰超出的最旧记录，返回淘汰的记录数
+func (c *Cache[K, V]) Resize(maxEntries int, maxCost int64) (evicted int) {
+	c.MaxEntries = maxEntries
+	c.MaxCost = maxCost
+	if c.cache == nil {
+		return 0
+	}
+	return c.evict()
+}
+
 // Remove removes the provided key from the cache.
 // Remove 移除指定key的缓存记录
-func (c *Cache) Remove(key Key) {
+func (c *Cache[K, V]) Remove(key K) {
 	if c.cache == nil {
 		return
 	}
-	if ele, hit := c.cache[key]; hit {
-		c.removeElement(ele)
+	if e, hit := c.cache[key]; hit {
+		c.removeEntry(e)
 	}
 }
 
 // RemoveOldest removes the oldest item from the cache.
 // RemoveOldest 移除最旧的缓存记录
-func (c *Cache) RemoveOldest() {
+func (c *Cache[K, V]) RemoveOldest() {
 	if c.cache == nil {
 		return
 	}
-	// else赋值为链表尾部节点指针
-	ele := c.ll.Back()
-	if ele != nil {
-		c.removeElement(ele)
+	// 链表尾部为最旧记录
+	if e := c.root.prev; e != &c.root {
+		c.removeEntry(e)
 	}
 }
 
 // 移除记录节点
-func (c *Cache) removeElement(e *list.Element) {
+func (c *Cache[K, V]) removeEntry(e *entry[K, V]) {
 	// 移除链表中节点
-	c.ll.Remove(e)
-	// e.value是interface{}类型，通过类型断言转换为*entry类型 记录指针
-	// entry结构体 包含key、value两个属性
-	kv := e.Value.(*entry)
-	delete(c.cache, kv.key)
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next = nil, nil
+	delete(c.cache, e.key)
+	c.cost -= e.cost
 	// 如果存在清除回调函数，触发清除回调
 	if c.OnEvicted != nil {
-		c.OnEvicted(kv.key, kv.value)
+		c.OnEvicted(e.key, e.value)
 	}
 }
 
+// 将记录插入链表头部
+func (c *Cache[K, V]) pushFront(e *entry[K, V]) {
+	e.prev = &c.root
+	e.next = c.root.next
+	e.prev.next = e
+	e.next.prev = e
+}
+
+// 将记录移动至链表头部
+func (c *Cache[K, V]) moveToFront(e *entry[K, V]) {
+	if c.root.next == e {
+		return
+	}
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	c.pushFront(e)
+}
+
 // Len returns the number of items in the cache.
 // 返回缓存记录数目
-func (c *Cache) Len() int {
-	if c.cache == nil {
-		return 0
-	}
-	return c.ll.Len()
+func (c *Cache[K, V]) Len() int {
+	return len(c.cache)
+}
+
+// TotalCost returns the total cost of the items in the cache.
+// 返回缓存记录的总开销
+func (c *Cache[K, V]) TotalCost() int64 {
+	return c.cost
 }
 
 // Clear purges all stored items from the cache.
 // 删除缓存所有记录
-func (c *Cache) Clear() {
-	// 如果清除回调函数存在，则清除节点时依次调用
-	if c.OnEvicted != nil {
-		for _, e := range c.cache {
-			kv := e.Value.(*entry)
-			c.OnEvicted(kv.key, kv.value)
+func (c *Cache[K, V]) Clear() {
+	// 如果清除回调函数存在，则从最旧的记录开始依次调用
+	if c.OnEvicted != nil && c.cache != nil {
+		for e := c.root.prev; e != &c.root; e = e.prev {
+			c.OnEvicted(e.key, e.value)
 		}
 	}
-	c.ll = nil
+	c.root = entry[K, V]{}
 	c.cache = nil
+	c.cost = 0
 }
@@ -95,3 +95,189 @@ func TestEvict(t *testing.T) {
 		t.Fatalf("got %v in second evicted key; want %s", evictedKeys[1], "myKey1")
 	}
 }
+
+func TestCache(t *testing.T) {
+	var evicted []string
+	c := &Cache[string, int]{
+		MaxEntries: 3,
+		OnEvicted:  func(key string, value int) { evicted = append(evicted, fmt.Sprintf("%s=%d", key, value)) },
+	}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v; want 1, true", v, ok)
+	}
+	c.Add("b", 20) // replaces the value, counting as a use
+	c.Add("d", 4)  // evicts c, the least recently used
+	c.Remove("a")
+	if got, want := fmt.Sprint(evicted), "[c=3 a=1]"; got != want {
+		t.Errorf("evicted %s; want %s", got, want)
+	}
+	if v, ok := c.Get("b"); !ok || v != 20 {
+		t.Errorf("Get(b) = %d, %v; want 20, true", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d; want 2", c.Len())
+	}
+
+	evicted = nil
+	c.Clear()
+	if got, want := fmt.Sprint(evicted), "[d=4 b=20]"; got != want {
+		t.Errorf("Clear evicted %s; want %s", got, want)
+	}
+	if _, ok := c.Get("b"); ok || c.Len() != 0 {
+		t.Error("Clear left entries behind")
+	}
+	c.Add("e", 5)
+	if v, ok := c.Get("e"); !ok || v != 5 {
+		t.Errorf("Get(e) after Clear = %d, %v; want 5, true", v, ok)
+	}
+}
+
+func TestMaxCost(t *testing.T) {
+	var evicted []string
+	c := &Cache[string, string]{
+		MaxCost:   10,
+		Cost:      func(key, value string) int64 { return int64(len(key) + len(value)) },
+		OnEvicted: func(key, value string) { evicted = append(evicted, key) },
+	}
+	c.Add("a", "1234") // 5
+	c.Add("b", "12")   // 3
+	c.Get("a")
+	c.Add("c", "1") // 2, total 10
+	if len(evicted) != 0 || c.TotalCost() != 10 {
+		t.Fatalf("evicted %v with cost %d; want none with cost 10", evicted, c.TotalCost())
+	}
+	c.Add("d", "12") // 3: evicts b, the oldest
+	if got, want := fmt.Sprint(evicted), "[b]"; got != want || c.TotalCost() != 10 {
+		t.Errorf("evicted %s with cost %d; want %s with cost 10", got, c.TotalCost(), want)
+	}
+	c.Add("c", "1234567") // replaced, cost 8: evicts a and d
+	if got, want := fmt.Sprint(evicted), "[b a d]"; got != want || c.TotalCost() != 8 {
+		t.Errorf("evicted %s with cost %d; want %s with cost 8", got, c.TotalCost(), want)
+	}
+	c.Add("e", "1234567890") // costs more than MaxCost on its own
+	if c.Len() != 0 || c.TotalCost() != 0 {
+		t.Errorf("Len() = %d, TotalCost() = %d after adding an entry over MaxCost; want 0, 0", c.Len(), c.TotalCost())
+	}
+	c.Remove("missing")
+	c.Add("f", "1")
+	c.Remove("f")
+	if c.TotalCost() != 0 {
+		t.Errorf("TotalCost() = %d after Remove; want 0", c.TotalCost())
+	}
+}
+
+func TestPeekContainsKeys(t *testing.T) {
+	c := NewCache[string, int](3)
+	if c.Contains("a") || len(c.Keys()) != 0 {
+		t.Error("empty cache has keys")
+	}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Errorf("Peek(a) = %d, %v; want 1, true", v, ok)
+	}
+	if !c.Contains("b") || c.Contains("z") {
+		t.Error("Contains is wrong")
+	}
+	// Peek and Contains don't count as uses, so a is still the oldest.
+	if got, want := fmt.Sprint(c.Keys()), "[a b c]"; got != want {
+		t.Errorf("Keys() = %s; want %s", got, want)
+	}
+	c.Add("d", 4)
+	if c.Contains("a") {
+		t.Error("a not evicted after Peek")
+	}
+	c.Get("b")
+	if got, want := fmt.Sprint(c.Keys()), "[c d b]"; got != want {
+		t.Errorf("Keys() = %s; want %s", got, want)
+	}
+}
+
+func TestResize(t *testing.T) {
+	c := NewCache[int, int](0)
+	for i := 0; i < 10; i++ {
+		c.Add(i, i)
+	}
+	if n := c.Resize(4, 0); n != 6 || c.Len() != 4 {
+		t.Errorf("Resize(4, 0) evicted %d, leaving %d; want 6, leaving 4", n, c.Len())
+	}
+	if got, want := fmt.Sprint(c.Keys()), "[6 7 8 9]"; got != want {
+		t.Errorf("Keys() = %s; want %s", got, want)
+	}
+	if n := c.Resize(0, 2); n != 2 || c.Len() != 2 {
+		t.Errorf("Resize(0, 2) evicted %d, leaving %d; want 2, leaving 2", n, c.Len())
+	}
+	if n := c.Resize(0, 0); n != 0 {
+		t.Errorf("Resize(0, 0) evicted %d; want 0", n)
+	}
+	for i := 0; i < 10; i++ {
+		c.Add(i, i)
+	}
+	if c.Len() != 10 {
+		t.Errorf("Len() = %d without limits; want 10", c.Len())
+	}
+}
+
+// The Add benchmarks evict an entry for each one they add.
+
+func BenchmarkAddAny(b *testing.B) {
+	c := New(1024)
+	keys := benchmarkKeys(4096)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Add(keys[i&4095], i)
+	}
+}
+
+func BenchmarkAdd(b *testing.B) {
+	c := NewCache[string, int](1024)
+	keys := benchmarkKeys(4096)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Add(keys[i&4095], i)
+	}
+}
+
+func BenchmarkGetAny(b *testing.B) {
+	c := New(0)
+	keys := benchmarkKeys(1024)
+	for i, key := range keys {
+		c.Add(key, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if v, ok := c.Get(keys[i&1023]); !ok || v.(int) != i&1023 {
+			b.Fatal("missing key")
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	c := NewCache[string, int](0)
+	keys := benchmarkKeys(1024)
+	for i, key := range keys {
+		c.Add(key, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if v, ok := c.Get(keys[i&1023]); !ok || v != i&1023 {
+			b.Fatal("missing key")
+		}
+	}
+}
+
+func benchmarkKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	return keys
+}
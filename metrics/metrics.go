@@ -0,0 +1,179 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics exports the statistics of groupcache groups in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"groupcache"
+)
+
+// ContentType is the content type of the exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler returns a handler serving the metrics of the groups of
+// groupcache.DefaultWorkspace.
+func Handler() http.Handler {
+	return NewHandler(groupcache.DefaultWorkspace)
+}
+
+// NewHandler returns a handler serving the metrics of the groups of
+// ws: the Stats of each group, the CacheStats of its main and hot
+// caches, and its Latencies. Every metric has a group label, and the
+// cache metrics a cache label of "main" or "hot".
+func NewHandler(ws *groupcache.Workspace) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		bw := bufio.NewWriter(w)
+		write(bw, ws.Groups())
+		bw.Flush()
+	})
+}
+
+// A stat is a field of groupcache.Stats.
+type stat struct {
+	name, help string
+	field      func(*groupcache.Stats) *groupcache.AtomicInt
+}
+
+var stats = []stat{
+	{"gets", "Get requests, including from peers.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.Gets }},
+	{"cache_hits", "Gets answered by either cache.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.CacheHits }},
+	{"peer_loads", "Loads answered by a peer, from its cache or Getter.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.PeerLoads }},
+	{"peer_errors", "Transport errors talking to peers.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.PeerErrors }},
+	{"loads", "Gets missing the caches.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.Loads }},
+	{"loads_deduped", "Loads left after suppressing duplicate calls.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.LoadsDeduped }},
+	{"loads_shared", "Loads whose result went to other callers too.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.LoadsShared }},
+	{"load_waiters", "Callers which got the result of a load started by another caller.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.LoadWaiters }},
+	{"local_loads", "Good loads with the group's Getter.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.LocalLoads }},
+	{"local_load_errs", "Bad loads with the group's Getter.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.LocalLoadErrs }},
+	{"server_requests", "Gets that came over the network from peers.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.ServerRequests }},
+	{"negative_hits", "Gets answered by a remembered negative result.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.NegativeHits }},
+	{"negative_loads", "Negative results remembered, from local loads or peers.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.NegativeLoads }},
+	{"replica_loads", "Peer loads answered by a replica after the primary owner failed.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.ReplicaLoads }},
+	{"replica_fills", "Values sent to the other owners of a key.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.ReplicaFills }},
+	{"replica_fill_errs", "Values which failed to reach an owner, or were dropped.", func(s *groupcache.Stats) *groupcache.AtomicInt { return &s.ReplicaFillErrs }},
+}
+
+// A cacheStat is a field of groupcache.CacheStats.
+type cacheStat struct {
+	name, typ, help string
+	field           func(groupcache.CacheStats) float64
+}
+
+var cacheStats = []cacheStat{
+	{"cache_bytes", "gauge", "Bytes of the keys and values in the cache.", func(s groupcache.CacheStats) float64 { return float64(s.Bytes) }},
+	{"cache_items", "gauge", "Items in the cache.", func(s groupcache.CacheStats) float64 { return float64(s.Items) }},
+	{"cache_gets_total", "counter", "Gets of the cache.", func(s groupcache.CacheStats) float64 { return float64(s.Gets) }},
+	{"cache_get_hits_total", "counter", "Gets of the cache which hit.", func(s groupcache.CacheStats) float64 { return float64(s.Hits) }},
+	{"cache_evictions_total", "counter", "Items evicted from the cache.", func(s groupcache.CacheStats) float64 { return float64(s.Evictions) }},
+	{"cache_rejects_total", "counter", "Values not admitted into the cache.", func(s groupcache.CacheStats) float64 { return float64(s.Rejects) }},
+	{"cache_admit_qps", "gauge", "Request rate at which values are admitted into the cache.", func(s groupcache.CacheStats) float64 { return s.AdmitQPS }},
+	{"cache_share", "gauge", "Share of the group's cache bytes.", func(s groupcache.CacheStats) float64 { return s.Share }},
+}
+
+// A histogram is a field of groupcache.Latencies.
+type histogram struct {
+	name, help string
+	field      func(*groupcache.Latencies) *groupcache.Histogram
+}
+
+var histograms = []histogram{
+	{"local_load_duration_seconds", "Duration of calls of the group's Getter.", func(l *groupcache.Latencies) *groupcache.Histogram { return &l.LocalLoads }},
+	{"peer_fetch_duration_seconds", "Duration of requests to peers.", func(l *groupcache.Latencies) *groupcache.Histogram { return &l.PeerFetches }},
+}
+
+// latencyBuckets are the bounds of the buckets of the histograms.
+var latencyBuckets = groupcache.LatencyBuckets()
+
+var caches = []struct {
+	label string
+	typ   groupcache.CacheType
+}{
+	{"main", groupcache.MainCache},
+	{"hot", groupcache.HotCache},
+}
+
+// write writes the metrics of groups to w in the exposition format.
+// The samples of each metric are written together, one per group.
+func write(w *bufio.Writer, groups []*groupcache.Group) {
+	for _, st := range stats {
+		writeHeader(w, st.name+"_total", "counter", st.help)
+		for _, g := range groups {
+			writeSample(w, st.name+"_total", groupLabel(g), float64(st.field(&g.Stats).Get()))
+		}
+	}
+
+	// Take each CacheStats once, so that the metrics of a cache
+	// agree with each other.
+	snapshots := make([][]groupcache.CacheStats, len(groups))
+	for i, g := range groups {
+		for _, c := range caches {
+			snapshots[i] = append(snapshots[i], g.CacheStats(c.typ))
+		}
+	}
+	for _, cs := range cacheStats {
+		writeHeader(w, cs.name, cs.typ, cs.help)
+		for i, g := range groups {
+			for j, c := range caches {
+				labels := groupLabel(g) + `,cache="` + c.label + `"`
+				writeSample(w, cs.name, labels, cs.field(snapshots[i][j]))
+			}
+		}
+	}
+
+	for _, h := range histograms {
+		writeHeader(w, h.name, "histogram", h.help)
+		for _, g := range groups {
+			writeHistogram(w, h.name, groupLabel(g), h.field(&g.Latencies).Snapshot())
+		}
+	}
+}
+
+func writeHistogram(w *bufio.Writer, name, labels string, s groupcache.HistogramSnapshot) {
+	var cum int64
+	for i, bound := range latencyBuckets {
+		cum += s.Counts[i]
+		le := strconv.FormatFloat(bound.Seconds(), 'g', -1, 64)
+		writeSample(w, name+"_bucket", labels+`,le="`+le+`"`, float64(cum))
+	}
+	writeSample(w, name+"_bucket", labels+`,le="+Inf"`, float64(s.Count))
+	writeSample(w, name+"_sum", labels, s.Sum.Seconds())
+	writeSample(w, name+"_count", labels, float64(s.Count))
+}
+
+func writeHeader(w *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP groupcache_%s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE groupcache_%s %s\n", name, typ)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, v float64) {
+	fmt.Fprintf(w, "groupcache_%s{%s} %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func groupLabel(g *groupcache.Group) string {
+	return `group="` + labelEscaper.Replace(g.Name()) + `"`
+}
+
+// labelEscaper escapes label values as the exposition format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,108 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"groupcache"
+)
+
+func TestHandler(t *testing.T) {
+	ws := groupcache.NewWorkspace()
+	g := ws.NewGroup(`a"b`, 1<<20, groupcache.GetterFunc(func(_ context.Context, key string, dest groupcache.Sink) error {
+		if key == "bad" {
+			return errors.New("bad key")
+		}
+		return dest.SetString("ECHO:" + key)
+	}))
+	ws.NewGroup("other", 1<<20, groupcache.GetterFunc(func(_ context.Context, key string, dest groupcache.Sink) error {
+		return dest.SetString(key)
+	}))
+	var s string
+	for _, key := range []string{"x", "x", "y", "bad"} {
+		g.Get(context.Background(), key, groupcache.StringSink(&s))
+	}
+
+	rec := httptest.NewRecorder()
+	NewHandler(ws).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q; want %q", ct, ContentType)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE groupcache_gets_total counter\n",
+		`groupcache_gets_total{group="a\"b"} 4` + "\n",
+		`groupcache_gets_total{group="other"} 0` + "\n",
+		`groupcache_cache_hits_total{group="a\"b"} 1` + "\n",
+		`groupcache_local_loads_total{group="a\"b"} 2` + "\n",
+		`groupcache_local_load_errs_total{group="a\"b"} 1` + "\n",
+		`groupcache_cache_items{group="a\"b",cache="main"} 2` + "\n",
+		`groupcache_cache_items{group="a\"b",cache="hot"} 0` + "\n",
+		"# TYPE groupcache_local_load_duration_seconds histogram\n",
+		`groupcache_local_load_duration_seconds_bucket{group="a\"b",le="+Inf"} 3` + "\n",
+		`groupcache_local_load_duration_seconds_count{group="a\"b"} 3` + "\n",
+		`groupcache_peer_fetch_duration_seconds_count{group="other"} 0` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q", want)
+		}
+	}
+
+	// The samples of each metric follow its header, without gaps.
+	seen := make(map[string]bool)
+	var family string
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			family = strings.Fields(line)[2]
+			if seen[family] {
+				t.Errorf("metric %s written twice", family)
+			}
+			seen[family] = true
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name := line[:strings.IndexAny(line, "{ ")]
+		if name != family && !strings.HasPrefix(name, family+"_") {
+			t.Errorf("sample %q is not of metric %s", line, family)
+		}
+	}
+}
+
+func TestAllStats(t *testing.T) {
+	if n := reflect.TypeOf(groupcache.Stats{}).NumField(); len(stats) != n {
+		t.Errorf("exporting %d stats; groupcache.Stats has %d", len(stats), n)
+	}
+	if n := reflect.TypeOf(groupcache.CacheStats{}).NumField(); len(cacheStats) != n {
+		t.Errorf("exporting %d cache stats; groupcache.CacheStats has %d", len(cacheStats), n)
+	}
+	var s groupcache.Stats
+	fields := make(map[*groupcache.AtomicInt]bool)
+	for _, st := range stats {
+		fields[st.field(&s)] = true
+	}
+	if len(fields) != len(stats) {
+		t.Errorf("stats export %d distinct fields; want %d", len(fields), len(stats))
+	}
+}
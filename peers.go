@@ -20,8 +20,15 @@ package groupcache
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	pb "github.com/golang/groupcache/groupcachepb"
+	"github.com/golang/protobuf/proto"
+	pb "groupcache/groupcachepb"
+	"groupcache/singleflight"
 )
 
 // ProtoGetter is the interface that must be implemented by a peer.
@@ -29,6 +36,32 @@ type ProtoGetter interface {
 	Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error
 }
 
+// Remover is implemented by peers which can remove keys from their
+// caches. Group.Remove fails for keys owned by other peers, and
+// leaves the hotCaches of other peers alone, unless they are
+// Removers.
+type Remover interface {
+	// Remove removes the key named by in from the peer's caches.
+	Remove(ctx context.Context, in *pb.GetRequest) error
+}
+
+// Setter is implemented by peers which can store values in their
+// caches. Group.Set fails for keys owned by other peers unless they
+// are Setters.
+type Setter interface {
+	// Set stores the value in in into the peer's mainCache.
+	Set(ctx context.Context, in *pb.SetRequest) error
+}
+
+// MultiGetter is implemented by peers which can get several keys of a
+// group in one request. Group.GetMulti gets keys from other peers one
+// at a time.
+type MultiGetter interface {
+	// GetMulti sets out.Responses to the responses for in.Keys, in
+	// order.
+	GetMulti(ctx context.Context, in *pb.GetMultiRequest, out *pb.GetMultiResponse) error
+}
+
 // PeerPicker is the interface that must be implemented to locate
 // the peer that owns a specific key.
 type PeerPicker interface {
@@ -38,24 +71,48 @@ type PeerPicker interface {
 	PickPeer(key string) (peer ProtoGetter, ok bool)
 }
 
+// PeerLister is implemented by PeerPickers which can list their
+// peers. Group.Remove removes keys from the hotCaches of the peers of
+// PeerListers.
+type PeerLister interface {
+	// GetAll returns all the remote peers, excluding the current
+	// peer.
+	GetAll() []ProtoGetter
+}
+
+// ReplicaPicker is implemented by PeerPickers which give each key
+// several owners. A Group that misses a key tries its owners in
+// order, up to and excluding itself, before loading the key locally,
+// and then sends the value to the other owners.
+type ReplicaPicker interface {
+	// PickPeers returns the owners of key in order of preference,
+	// the first being the owner PickPeer picks, with a nil
+	// ProtoGetter standing for the current peer. It returns nil if
+	// keys have only one owner.
+	PickPeers(key string) []ProtoGetter
+}
+
 // NoPeers is an implementation of PeerPicker that never finds a peer.
 type NoPeers struct{}
 
 func (NoPeers) PickPeer(key string) (peer ProtoGetter, ok bool) { return }
-
-var (
-	portPicker func(groupName string) PeerPicker
-)
+func (NoPeers) GetAll() []ProtoGetter                           { return nil }
 
 // RegisterPeerPicker registers the peer initialization function.
 // It is called once, when the first group is created.
 // Either RegisterPeerPicker or RegisterPerGroupPeerPicker should be
 // called exactly once, but not both.
 func RegisterPeerPicker(fn func() PeerPicker) {
-	if portPicker != nil {
+	DefaultWorkspace.RegisterPeerPicker(fn)
+}
+
+// RegisterPeerPicker registers the peer initialization function for
+// the groups of ws. See the package-level RegisterPeerPicker.
+func (ws *Workspace) RegisterPeerPicker(fn func() PeerPicker) {
+	if ws.portPicker != nil {
 		panic("RegisterPeerPicker called more than once")
 	}
-	portPicker = func(_ string) PeerPicker { return fn() }
+	ws.portPicker = func(_ string) PeerPicker { return fn() }
 }
 
 // RegisterPerGroupPeerPicker registers the peer initialization function,
@@ -64,19 +121,136 @@ func RegisterPeerPicker(fn func() PeerPicker) {
 // Either RegisterPeerPicker or RegisterPerGroupPeerPicker should be
 // called exactly once, but not both.
 func RegisterPerGroupPeerPicker(fn func(groupName string) PeerPicker) {
-	if portPicker != nil {
+	DefaultWorkspace.RegisterPerGroupPeerPicker(fn)
+}
+
+// RegisterPerGroupPeerPicker registers the per-group peer
+// initialization function for the groups of ws. See the package-level
+// RegisterPerGroupPeerPicker.
+func (ws *Workspace) RegisterPerGroupPeerPicker(fn func(groupName string) PeerPicker) {
+	if ws.portPicker != nil {
 		panic("RegisterPeerPicker called more than once")
 	}
-	portPicker = fn
+	ws.portPicker = fn
 }
 
-func getPeers(groupName string) PeerPicker {
-	if portPicker == nil {
+func (ws *Workspace) getPeers(groupName string) PeerPicker {
+	if ws.portPicker == nil {
 		return NoPeers{}
 	}
-	pk := portPicker(groupName)
+	pk := ws.portPicker(groupName)
 	if pk == nil {
 		pk = NoPeers{}
 	}
 	return pk
 }
+
+// serveGet answers a Get request that came over the network from a
+// peer.
+func (g *Group) serveGet(ctx context.Context, key string) (*pb.GetResponse, error) {
+	g.Stats.ServerRequests.Add(1)
+	var value ByteView
+	if err := g.Get(ctx, key, ByteViewSink(&value)); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		// Pass the Getter's error on to the peer.
+		res := &pb.GetResponse{
+			ErrorCode:    proto.Int32(int32(errorCode(err))),
+			ErrorMessage: proto.String(err.Error()),
+		}
+		// Let the peer remember a negative result for as long as
+		// this process does.
+		if _, expire, ok := g.negCache.get(key); ok {
+			res.NotFound = proto.Bool(true)
+			res.Expire = proto.Int64(expire.UnixNano())
+		}
+		return res, nil
+	}
+	res := &pb.GetResponse{
+		Value:     value.ByteSlice(),
+		MinuteQps: proto.Float64(g.rates.qps(key, time.Now())),
+	}
+	if e := value.Expire(); !e.IsZero() {
+		res.Expire = proto.Int64(e.UnixNano())
+	}
+	return res, nil
+}
+
+const (
+	// maxGetMultiKeys is the most keys a peer asks for, and serves,
+	// in one GetMulti request. Group.GetMulti splits larger batches.
+	maxGetMultiKeys = 1000
+
+	// getMultiWorkers is the number of keys of a GetMulti request
+	// which a peer gets at once.
+	getMultiWorkers = 16
+)
+
+// errTooManyKeys is the error of a GetMulti request of more than
+// maxGetMultiKeys keys.
+var errTooManyKeys = fmt.Errorf("groupcache: more than %d keys in one GetMulti request", maxGetMultiKeys)
+
+// serveGetMulti answers a peer's request for keys, getting up to
+// getMultiWorkers keys concurrently.
+func (g *Group) serveGetMulti(ctx context.Context, keys []string) (*pb.GetMultiResponse, error) {
+	if len(keys) > maxGetMultiKeys {
+		return nil, errTooManyKeys
+	}
+	res := &pb.GetMultiResponse{Responses: make([]*pb.GetResponse, len(keys))}
+	errs := make([]error, len(keys))
+	workers := getMultiWorkers
+	if len(keys) < workers {
+		workers = len(keys)
+	}
+	next := int64(-1) // the last key taken by a worker
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(keys) {
+					return
+				}
+				res.Responses[i], errs[i] = g.serveGetMultiKey(ctx, keys[i])
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// serveGetMultiKey answers for one key of a GetMulti request. A panic
+// of the Getter becomes the key's error, since the worker serving the
+// key has no caller to raise it in and would kill the process.
+func (g *Group) serveGetMultiKey(ctx context.Context, key string) (res *pb.GetResponse, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if p, ok := r.(*singleflight.PanicError); ok {
+				r = p.Value
+			}
+			res, err = &pb.GetResponse{
+				ErrorCode:    proto.Int32(int32(CodeUnknown)),
+				ErrorMessage: proto.String(fmt.Sprintf("groupcache: Getter panicked: %v", r)),
+			}, nil
+		}
+	}()
+	return g.serveGet(ctx, key)
+}
+
+// serveSet stores a value sent by a peer in the mainCache.
+func (g *Group) serveSet(key string, in *pb.SetRequest) {
+	value := ByteView{b: in.Value}
+	if in.Expire != nil {
+		value.e = time.Unix(0, *in.Expire)
+	}
+	g.negCache.remove(key)
+	g.populateCache(key, value, &g.mainCache)
+}
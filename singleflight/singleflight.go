@@ -19,14 +19,69 @@ limitations under the License.
 // mechanism.
 package singleflight
 
-import "sync"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrGoexit is the error of the waiters of a call whose function
+// called runtime.Goexit.
+// 函数调用runtime.Goexit时，等待者收到的错误
+var ErrGoexit = errors.New("singleflight: function called runtime.Goexit")
+
+// A PanicError is a panic of the function of a call, captured with
+// the stack of the goroutine that panicked. Do and DoContext panic
+// with it in every caller, and DoChan passes it on as the error.
+// PanicError 保存函数的panic值及其调用栈
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("singleflight: panic: %v\n\n%s", p.Value, p.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+func newPanicError(v interface{}) *PanicError {
+	stack := debug.Stack()
+	// The first line of the stack is "goroutine N [running]:",
+	// which is misleading once re-raised in another goroutine.
+	if i := bytes.IndexByte(stack, '\n'); i >= 0 {
+		stack = stack[i+1:]
+	}
+	return &PanicError{Value: v, Stack: stack}
+}
 
 // call is an in-flight or completed Do call
 // call 是在执行的或者已经完成的Do过程
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done chan struct{} // closed when val and err are set
+	val  interface{}
+	err  error
+
+	// ctx is passed to the function of DoContext and DoChan, and
+	// cancelled once no caller waits for the call any more.
+	// ctx 传给函数，所有调用方都放弃等待后被取消
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int // guarded by Group.mu
+
+	// dups is the number of callers, other than any one of them,
+	// which receive the results, set once finished.
+	// dups 共享结果的其他调用方数量，调用完成时设置
+	dups     int
+	finished bool // guarded by Group.mu
 }
 
 // Group represents a class of work and forms a namespace in which
@@ -37,41 +92,203 @@ type Group struct {
 	m  map[string]*call // lazily initialized
 }
 
+// Result holds the results of Do, so they can be passed on a channel.
+// Result 保存Do的执行结果，以便通过channel传递
+type Result struct {
+	Val  interface{}
+	Err  error
+	Dups int // as returned by Do
+}
+
 // Do executes and returns the results of the given function, making
 // sure that only one execution is in-flight for a given key at a
 // time. If a duplicate comes in, the duplicate caller waits for the
 // original to complete and receives the same results.
+// dups is the number of other callers which received the same
+// results; the results were shared if it is positive.
 // Do 接收函数，执行并返回执行结果
 // 确保执行过程中，只有一个key在同一时间执行
 // 如果是重复调用，会等待最原始的调用完成，接收到相同的结果
-func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+// dups 为收到相同结果的其他调用方数量，大于0表示结果被共享
+func (g *Group) Do(key string, fn func() (interface{}, error)) (v interface{}, err error, dups int) {
+	c, created := g.join(context.Background(), key)
+	if created {
+		// 第一个调用方自己执行函数
+		g.doCall(c, key, fn)
+	} else {
+		// 等待原始调用完成，然后返回其val和err
+		<-c.done
+	}
+	return c.results()
+}
+
+// DoContext is like Do, except that the caller gives up waiting, and
+// returns ctx.Err(), when ctx ends before the call completes. The
+// function runs in its own goroutine with a context which carries the
+// values of the first caller's ctx, but not its deadline, and which
+// is cancelled once every caller has given up, so that one impatient
+// caller does not fail the others.
+// DoContext 与Do类似，但调用方的ctx结束时放弃等待并返回ctx.Err()
+// 函数在单独的goroutine中执行，所有调用方都放弃后，函数的ctx被取消
+func (g *Group) DoContext(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (v interface{}, err error, dups int) {
+	c, _ := g.start(ctx, key, fn)
+	return g.wait(ctx, key, c)
+}
+
+// DoChan is like DoContext, but returns a channel which receives the
+// results when they are ready, or ctx.Err() when ctx ends first. A
+// panic of fn is received as a *PanicError.
+// DoChan 与DoContext类似，但返回一个channel，在结果就绪时接收结果
+func (g *Group) DoChan(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) <-chan Result {
+	ch, _ := g.Start(ctx, key, fn)
+	return ch
+}
+
+// Start is like DoChan, and also reports whether the caller joined a
+// call already in flight for key, in which case fn is not called.
+// Start 与DoChan类似，并报告是否加入了key对应正在执行的调用
+func (g *Group) Start(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (ch <-chan Result, joined bool) {
+	res := make(chan Result, 1)
+	c, created := g.start(ctx, key, fn)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				res <- Result{Err: r.(*PanicError), Dups: c.dups}
+			}
+		}()
+		v, err, dups := g.wait(ctx, key, c)
+		res <- Result{Val: v, Err: err, Dups: dups}
+	}()
+	return res, !created
+}
+
+// Forget forgets the call in flight for key, if any, so that the next
+// call for key starts afresh rather than waiting for its results.
+// Forget 忘记key对应正在执行的调用，下一次调用将重新执行
+func (g *Group) Forget(key string) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+}
+
+// join returns the call in flight for key, and whether it was just
+// created, counting the caller among its waiters.
+// join 返回key对应正在执行的调用，不存在则创建
+func (g *Group) join(ctx context.Context, key string) (c *call, created bool) {
 	g.mu.Lock()
+	defer g.mu.Unlock()
 	// 如果g.m为尚未初始化，则初始化
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
-	// 如果key存在同名调用，则等待原始调用完成，然后返回其val和err
 	if c, ok := g.m[key]; ok {
-		g.mu.Unlock()
-		c.wg.Wait()
-		// 等待goroutine执行完成，call中存储来执行的结果val和err
-		return c.val, c.err
+		c.waiters++
+		return c, false
 	}
-	// 拿到call结构体的指针
-	c := new(call)
-	// 因为一个函数只执行一次，所以wg.add(1), 同一key的fn函数只调用一次
-	c.wg.Add(1)
+	c = &call{done: make(chan struct{}), waiters: 1}
+	c.ctx, c.cancel = context.WithCancel(detached{ctx})
 	g.m[key] = c
-	g.mu.Unlock()
+	return c, true
+}
+
+// start joins the call for key, running fn in a new goroutine if
+// there was none, in which case created is true.
+func (g *Group) start(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (c *call, created bool) {
+	c, created = g.join(ctx, key)
+	if created {
+		go g.doCall(c, key, func() (interface{}, error) { return fn(c.ctx) })
+	}
+	return c, created
+}
+
+// doCall runs fn for c and publishes its results, even if fn panics
+// or calls runtime.Goexit, in which case c.err is a *PanicError or
+// ErrGoexit.
+// doCall 执行函数并发布结果，函数panic或调用runtime.Goexit时同样发布
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	returned, recovered := false, false
+	defer func() {
+		// Neither returned nor panicked: fn called runtime.Goexit,
+		// which goes on unwinding this goroutine.
+		if !returned && !recovered {
+			c.err = ErrGoexit
+		}
+		c.cancel()
+
+		g.mu.Lock()
+		if c.waiters > 1 {
+			c.dups = c.waiters - 1
+		}
+		c.finished = true
+		// 执行完成，删除对应key；放弃的调用可能已被新的调用替换
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	func() {
+		defer func() {
+			if !returned {
+				if r := recover(); r != nil {
+					c.val, c.err = nil, newPanicError(r)
+				}
+			}
+		}()
+		// 函数调用完成，返回结果和错误信息
+		c.val, c.err = fn()
+		returned = true
+	}()
+	recovered = !returned
+}
 
-	// 函数调用完成，返回结果和错误信息
-	c.val, c.err = fn()
-	c.wg.Done()
+// results returns the results of c, which has completed, panicking
+// again if its function panicked.
+func (c *call) results() (interface{}, error, int) {
+	if p, ok := c.err.(*PanicError); ok {
+		panic(p)
+	}
+	return c.val, c.err, c.dups
+}
 
+// wait waits for c to complete or for ctx to end. A caller which
+// gives up no longer counts as a waiter of c, and the last one to
+// give up cancels c and lets the next caller for key start afresh.
+// wait 等待调用完成或ctx结束；最后一个放弃的调用方取消调用
+func (g *Group) wait(ctx context.Context, key string, c *call) (interface{}, error, int) {
+	select {
+	case <-c.done:
+		return c.results()
+	case <-ctx.Done():
+	}
 	g.mu.Lock()
-	// 执行完成，删除对应key
-	delete(g.m, key)
+	if c.finished {
+		// Completed meanwhile, counting this caller among the
+		// ones receiving the results.
+		g.mu.Unlock()
+		<-c.done
+		return c.results()
+	}
+	c.waiters--
+	if c.waiters == 0 {
+		c.cancel()
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+	}
 	g.mu.Unlock()
+	return nil, ctx.Err(), 0
+}
 
-	return c.val, c.err
+// detached is a context which carries the values of its parent, but
+// not its deadline or cancellation, so that a call can outlive the
+// caller which started it.
+// detached 保留父context的值，但不继承其截止时间和取消
+type detached struct {
+	context.Context
 }
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
@@ -17,8 +17,10 @@ limitations under the License.
 package singleflight
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -27,12 +29,15 @@ import (
 
 func TestDo(t *testing.T) {
 	var g Group
-	v, err := g.Do("key", func() (interface{}, error) {
+	v, err, dups := g.Do("key", func() (interface{}, error) {
 		return "bar", nil
 	})
 	if got, want := fmt.Sprintf("%v (%T)", v, v), "bar (string)"; got != want {
 		t.Errorf("Do = %v; want %v", got, want)
 	}
+	if dups != 0 {
+		t.Errorf("Do dups = %d; want 0", dups)
+	}
 	if err != nil {
 		t.Errorf("Do error = %v", err)
 	}
@@ -41,7 +46,7 @@ func TestDo(t *testing.T) {
 func TestDoErr(t *testing.T) {
 	var g Group
 	someErr := errors.New("some error")
-	v, err := g.Do("key", func() (interface{}, error) {
+	v, err, _ := g.Do("key", func() (interface{}, error) {
 		return nil, someErr
 	})
 	if err != someErr {
@@ -66,13 +71,16 @@ func TestDoDupSuppress(t *testing.T) {
 	for i := 0; i < n; i++ {
 		wg.Add(1)
 		go func() {
-			v, err := g.Do("key", fn)
+			v, err, dups := g.Do("key", fn)
 			if err != nil {
 				t.Errorf("Do error: %v", err)
 			}
 			if v.(string) != "bar" {
 				t.Errorf("got %q; want %q", v, "bar")
 			}
+			if dups != n-1 {
+				t.Errorf("dups = %d; want %d", dups, n-1)
+			}
 			wg.Done()
 		}()
 	}
@@ -83,3 +91,261 @@ func TestDoDupSuppress(t *testing.T) {
 		t.Errorf("number of calls = %d; want 1", got)
 	}
 }
+
+func TestDoContextAbandon(t *testing.T) {
+	var g Group
+	release := make(chan string)
+	started := make(chan context.Context, 1)
+	fn := func(ctx context.Context) (interface{}, error) {
+		started <- ctx
+		return <-release, nil
+	}
+
+	// The first caller gives up; the second still gets the result.
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ch1 := g.DoChan(ctx1, "key", fn)
+	fctx := <-started
+	ch2 := g.DoChan(context.Background(), "key", fn)
+	cancel1()
+	if r := <-ch1; r.Err != context.Canceled {
+		t.Errorf("abandoned DoChan = %v, %v; want context.Canceled", r.Val, r.Err)
+	}
+	if err := fctx.Err(); err != nil {
+		t.Errorf("fn's context ended with %v while a caller waits", err)
+	}
+	release <- "bar"
+	if r := <-ch2; r.Val != "bar" || r.Err != nil || r.Dups != 0 {
+		t.Errorf("DoChan = %v, %v with %d dups; want bar unshared", r.Val, r.Err, r.Dups)
+	}
+}
+
+func TestDoContextCancel(t *testing.T) {
+	var g Group
+	cancelled := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}
+
+	// Once every caller has given up, fn's context is cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err, _ := g.DoContext(ctx, "key", fn); err != context.DeadlineExceeded {
+		t.Errorf("DoContext error = %v; want context.DeadlineExceeded", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("fn's context not cancelled after every caller gave up")
+	}
+
+	// The next caller starts a fresh call rather than joining the
+	// cancelled one.
+	v, err, _ := g.DoContext(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return "fresh", nil
+	})
+	if v != "fresh" || err != nil {
+		t.Errorf("DoContext after cancel = %v, %v; want fresh", v, err)
+	}
+}
+
+func TestDoContextValues(t *testing.T) {
+	var g Group
+	type ctxKey struct{}
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), ctxKey{}, "value"), time.Hour)
+	defer cancel()
+	v, err, _ := g.DoContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		if _, ok := ctx.Deadline(); ok {
+			return nil, errors.New("fn got the caller's deadline")
+		}
+		return ctx.Value(ctxKey{}), nil
+	})
+	if v != "value" || err != nil {
+		t.Errorf("DoContext = %v, %v; want the caller's value", v, err)
+	}
+}
+
+func TestDoJoinsDoContext(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	ch := g.DoChan(context.Background(), "key", func(context.Context) (interface{}, error) {
+		<-release
+		return "first", nil
+	})
+	res := make(chan interface{})
+	go func() {
+		v, _, _ := g.Do("key", func() (interface{}, error) { return "second", nil })
+		res <- v
+	}()
+	for waiters := 0; waiters < 2; time.Sleep(time.Millisecond) {
+		g.mu.Lock()
+		waiters = g.m["key"].waiters
+		g.mu.Unlock()
+	}
+	close(release)
+	if v := <-res; v != "first" {
+		t.Errorf("Do = %v; want the result of the call in flight", v)
+	}
+	if r := <-ch; r.Val != "first" {
+		t.Errorf("DoChan = %v; want first", r.Val)
+	}
+}
+
+func TestDoPanic(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		panic("boom")
+	}
+	do := func() (p interface{}) {
+		defer func() { p = recover() }()
+		g.Do("key", fn)
+		return nil
+	}
+
+	// The caller running fn and the one waiting both panic.
+	res := make(chan interface{}, 2)
+	go func() { res <- do() }()
+	for waiters := 0; waiters < 1; time.Sleep(time.Millisecond) {
+		g.mu.Lock()
+		if c := g.m["key"]; c != nil {
+			waiters = c.waiters
+		}
+		g.mu.Unlock()
+	}
+	go func() { res <- do() }()
+	for waiters := 0; waiters < 2; time.Sleep(time.Millisecond) {
+		g.mu.Lock()
+		waiters = g.m["key"].waiters
+		g.mu.Unlock()
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		p, ok := (<-res).(*PanicError)
+		if !ok || p.Value != "boom" || len(p.Stack) == 0 {
+			t.Errorf("Do panicked with %#v; want a *PanicError of boom", p)
+		}
+	}
+
+	// Later calls don't deadlock.
+	if v, err, _ := g.Do("key", func() (interface{}, error) { return "bar", nil }); v != "bar" || err != nil {
+		t.Errorf("Do after panic = %v, %v; want bar", v, err)
+	}
+}
+
+func TestDoContextPanic(t *testing.T) {
+	var g Group
+	someErr := errors.New("some error")
+	fn := func(context.Context) (interface{}, error) { panic(someErr) }
+	func() {
+		defer func() {
+			p, ok := recover().(*PanicError)
+			if !ok || !errors.Is(p, someErr) {
+				t.Errorf("DoContext panicked with %#v; want a *PanicError of someErr", p)
+			}
+		}()
+		g.DoContext(context.Background(), "key", fn)
+		t.Error("DoContext returned after fn panicked")
+	}()
+
+	// DoChan passes the panic on as an error.
+	r := <-g.DoChan(context.Background(), "key", fn)
+	if p, ok := r.Err.(*PanicError); !ok || p.Value != someErr {
+		t.Errorf("DoChan error = %v; want a *PanicError of someErr", r.Err)
+	}
+}
+
+func TestDoGoexit(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Do("key", func() (interface{}, error) {
+			<-release
+			runtime.Goexit()
+			return nil, nil
+		})
+		t.Error("Do returned after fn called runtime.Goexit")
+	}()
+	for waiters := 0; waiters < 1; time.Sleep(time.Millisecond) {
+		g.mu.Lock()
+		if c := g.m["key"]; c != nil {
+			waiters = c.waiters
+		}
+		g.mu.Unlock()
+	}
+	ch := g.DoChan(context.Background(), "key", nil)
+	close(release)
+	<-done
+	if r := <-ch; r.Err != ErrGoexit {
+		t.Errorf("waiter got %v, %v; want ErrGoexit", r.Val, r.Err)
+	}
+
+	// The same goes for functions run in a goroutine of their own.
+	r := <-g.DoChan(context.Background(), "key", func(context.Context) (interface{}, error) {
+		runtime.Goexit()
+		return nil, nil
+	})
+	if r.Err != ErrGoexit {
+		t.Errorf("DoChan = %v, %v; want ErrGoexit", r.Val, r.Err)
+	}
+	if v, err, _ := g.Do("key", func() (interface{}, error) { return "bar", nil }); v != "bar" || err != nil {
+		t.Errorf("Do after Goexit = %v, %v; want bar", v, err)
+	}
+}
+
+func TestForget(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	ch1 := g.DoChan(context.Background(), "key", func(context.Context) (interface{}, error) {
+		<-release
+		return "first", nil
+	})
+
+	// After Forget, callers start a fresh call.
+	g.Forget("key")
+	v, err, _ := g.Do("key", func() (interface{}, error) { return "second", nil })
+	if v != "second" || err != nil {
+		t.Errorf("Do after Forget = %v, %v; want second", v, err)
+	}
+
+	// The forgotten call still completes for its callers, and does
+	// not remove the fresh call of a later caller.
+	ch3 := g.DoChan(context.Background(), "key", func(context.Context) (interface{}, error) {
+		<-release
+		return "third", nil
+	})
+	ch4 := g.DoChan(context.Background(), "key", nil)
+	close(release)
+	if r := <-ch1; r.Val != "first" {
+		t.Errorf("forgotten call = %v; want first", r.Val)
+	}
+	if r3, r4 := <-ch3, <-ch4; r3.Val != "third" || r4.Val != "third" || r3.Dups != 1 || r4.Dups != 1 {
+		t.Errorf("fresh call = %v, %v with %d, %d dups; want third shared by both", r3.Val, r4.Val, r3.Dups, r4.Dups)
+	}
+}
+
+func TestStart(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	ch1, joined1 := g.Start(context.Background(), "key", func(context.Context) (interface{}, error) {
+		<-release
+		return "first", nil
+	})
+	ch2, joined2 := g.Start(context.Background(), "key", func(context.Context) (interface{}, error) {
+		t.Error("fn of a joined call ran")
+		return "second", nil
+	})
+	if joined1 || !joined2 {
+		t.Errorf("joined = %v, %v; want false, true", joined1, joined2)
+	}
+	close(release)
+	for _, ch := range []<-chan Result{ch1, ch2} {
+		if r := <-ch; r.Val != "first" || r.Err != nil || r.Dups != 1 {
+			t.Errorf("Start = %v, %v with %d dups; want first shared once", r.Val, r.Err, r.Dups)
+		}
+	}
+}
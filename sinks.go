@@ -18,6 +18,7 @@ package groupcache
 
 import (
 	"errors"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 )
@@ -40,6 +41,13 @@ type Sink interface {
 	// The caller retains ownership of m.
 	SetProto(m proto.Message) error
 
+	// SetExpire sets the time at which the value expires from the
+	// group's caches. It may be called before or after the other
+	// Set methods. The zero time means the value never expires.
+	// SetExpire 设置值在缓存中的过期时间，可以在其他Set方法之前或之后调用
+	// 零值表示永不过期
+	SetExpire(e time.Time)
+
 	// view returns a frozen view of the bytes for caching.
 	// view 返回一个冻结的字节视图用以缓存
 	view() (ByteView, error)
@@ -112,6 +120,11 @@ func (s *stringSink) SetBytes(v []byte) error {
 	return s.SetString(string(v))
 }
 
+// 设置stringSink的ByteView的过期时间
+func (s *stringSink) SetExpire(e time.Time) {
+	s.v.e = e
+}
+
 // 从proto.Message中获取数据，写入stringSink的sp和v.b中
 func (s *stringSink) SetProto(m proto.Message) error {
 	b, err := proto.Marshal(m)
@@ -134,6 +147,7 @@ func ByteViewSink(dst *ByteView) Sink {
 
 type byteViewSink struct {
 	dst *ByteView
+	e   time.Time // expiry applied to values set on dst
 
 	// if this code ever ends up tracking that at least one set*
 	// method was called, don't make it an error to call set
@@ -163,25 +177,31 @@ func (s *byteViewSink) view() (ByteView, error) {
 	return *s.dst, nil
 }
 
+// 设置byteViewSink的dst的过期时间
+func (s *byteViewSink) SetExpire(e time.Time) {
+	s.e = e
+	s.dst.e = e
+}
+
 // 设置byteViewSink的dst，参数类型为proto.Message
 func (s *byteViewSink) SetProto(m proto.Message) error {
 	b, err := proto.Marshal(m)
 	if err != nil {
 		return err
 	}
-	*s.dst = ByteView{b: b}
+	*s.dst = ByteView{b: b, e: s.e}
 	return nil
 }
 
 // 设置byteViewSink的dst，参数类型为字节切片[]byte
 func (s *byteViewSink) SetBytes(b []byte) error {
-	*s.dst = ByteView{b: cloneBytes(b)}
+	*s.dst = ByteView{b: cloneBytes(b), e: s.e}
 	return nil
 }
 
 // 设置byteViewSink的dst，参数类型为string
 func (s *byteViewSink) SetString(v string) error {
-	*s.dst = ByteView{s: v}
+	*s.dst = ByteView{s: v, e: s.e}
 	return nil
 }
 
@@ -206,6 +226,11 @@ func (s *protoSink) view() (ByteView, error) {
 	return s.v, nil
 }
 
+// 设置protoSink的ByteView的过期时间
+func (s *protoSink) SetExpire(e time.Time) {
+	s.v.e = e
+}
+
 // 将s.dst反序列化后给b，并且复制b赋值给protoSink中的ByteView的b
 func (s *protoSink) SetBytes(b []byte) error {
 	err := proto.Unmarshal(b, s.dst)
@@ -282,6 +307,11 @@ func (s *allocBytesSink) setView(v ByteView) error {
 	return nil
 }
 
+// 设置allocBytesSink的ByteView的过期时间
+func (s *allocBytesSink) SetExpire(e time.Time) {
+	s.v.e = e
+}
+
 // 设置allocByteSink的dst和ByteView，参数类型为proto.Message
 func (s *allocBytesSink) SetProto(m proto.Message) error {
 	b, err := proto.Marshal(m)
@@ -340,6 +370,11 @@ func (s *truncBytesSink) view() (ByteView, error) {
 	return s.v, nil
 }
 
+// 设置truncBytesSink的ByteView的过期时间
+func (s *truncBytesSink) SetExpire(e time.Time) {
+	s.v.e = e
+}
+
 // 参数类型为proto.Message,设置truncBytesSink的ByteView
 func (s *truncBytesSink) SetProto(m proto.Message) error {
 	b, err := proto.Marshal(m)
@@ -0,0 +1,168 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package groupcache
+
+import (
+	"sync"
+	"time"
+)
+
+// SplitStats are the measurements from which a group's CacheSplit
+// decides how to split the group's cache bytes between its mainCache
+// and its hotCache.
+type SplitStats struct {
+	// HotShare is the current share of the hotCache.
+	HotShare float64
+
+	// Main and Hot are the stats of the caches. Bytes and Items are
+	// current, the other fields count since the previous split.
+	Main, Hot CacheStats
+
+	// PeerLatency is the mean latency of fetches from peers, and
+	// LocalLatency that of loads by the group's Getter. Recent
+	// loads weigh the most.
+	PeerLatency  time.Duration
+	LocalLatency time.Duration
+}
+
+// A CacheSplit returns the share, between 0 and 1, of a group's cache
+// bytes which its hotCache may hold before it is evicted from ahead of
+// the mainCache. It is called about once per second while the group
+// evicts.
+type CacheSplit func(s SplitStats) float64
+
+// defaultHotShare lets the hotCache hold an eighth as many bytes as
+// the mainCache.
+const defaultHotShare = 1.0 / 9
+
+// splitPeriod is how often the split of a group is decided.
+const splitPeriod = time.Second
+
+const (
+	minHotShare = 1.0 / 64
+	maxHotShare = 1.0 / 2
+
+	// hotShareStep is the factor by which AdaptiveSplit changes
+	// the hotCache's share at a time.
+	hotShareStep = 1.25
+
+	// latencyWeight is the weight of a load in the mean latencies.
+	latencyWeight = 0.1
+)
+
+// AdaptiveSplit is a CacheSplit which gives more bytes to whichever
+// cache saves more load time per byte. A hit in the hotCache saves a
+// fetch from a peer, and a hit in the mainCache a load by the Getter.
+// Only a cache which evicted since the previous split grows, and the
+// hotCache's share stays between 1/64 and 1/2.
+func AdaptiveSplit(s SplitStats) float64 {
+	hotValue := float64(s.Hot.Hits) * float64(s.PeerLatency) / float64(s.Hot.Bytes+1)
+	mainValue := float64(s.Main.Hits) * float64(s.LocalLatency) / float64(s.Main.Bytes+1)
+	share := s.HotShare
+	switch {
+	case s.Hot.Evictions > 0 && hotValue > mainValue:
+		share *= hotShareStep
+	case s.Main.Evictions > 0 && mainValue > hotValue:
+		share /= hotShareStep
+	}
+	if share < minHotShare {
+		share = minHotShare
+	}
+	if share > maxHotShare {
+		share = maxHotShare
+	}
+	return share
+}
+
+// splitState holds a group's split of its cache bytes, and the
+// measurements for deciding it.
+type splitState struct {
+	mu           sync.Mutex
+	hotShare     float64
+	decided      bool // whether hotShare was set
+	last         time.Time
+	main, hot    CacheStats // as of last
+	peerLatency  time.Duration
+	localLatency time.Duration
+}
+
+// peerLoaded records a fetch from a peer which took d.
+func (s *splitState) peerLoaded(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peerLatency = meanLatency(s.peerLatency, d)
+}
+
+// localLoaded records a load by the Getter which took d.
+func (s *splitState) localLoaded(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.localLatency = meanLatency(s.localLatency, d)
+}
+
+func meanLatency(mean, d time.Duration) time.Duration {
+	if mean == 0 {
+		return d
+	}
+	return mean + time.Duration(latencyWeight*float64(d-mean))
+}
+
+// share returns the hotCache's share of g's cache bytes, deciding it
+// anew with g's CacheSplit once splitPeriod passed.
+func (s *splitState) share(g *Group, now time.Time) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.decided {
+		s.hotShare, s.decided = defaultHotShare, true
+	}
+	if g.opts.CacheSplit == nil || (!s.last.IsZero() && now.Sub(s.last) < splitPeriod) {
+		return s.hotShare
+	}
+	main, hot := g.mainCache.stats(), g.hotCache.stats()
+	share := g.opts.CacheSplit(SplitStats{
+		HotShare:     s.hotShare,
+		Main:         main.since(s.main),
+		Hot:          hot.since(s.hot),
+		PeerLatency:  s.peerLatency,
+		LocalLatency: s.localLatency,
+	})
+	if share >= 0 && share <= 1 {
+		s.hotShare = share
+	}
+	s.last, s.main, s.hot = now, main, hot
+	return s.hotShare
+}
+
+// current returns the hotCache's share of g's cache bytes.
+func (s *splitState) current() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.decided {
+		return defaultHotShare
+	}
+	return s.hotShare
+}
+
+// since returns the stats counted since prev. Bytes and Items are
+// current.
+func (s CacheStats) since(prev CacheStats) CacheStats {
+	s.Gets -= prev.Gets
+	s.Hits -= prev.Hits
+	s.Evictions -= prev.Evictions
+	s.Rejects -= prev.Rejects
+	return s
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tinylfu
+
+const (
+	sketchDepth = 4
+	maxCount    = 15 // counters saturate, like 4-bit counters
+
+	// sketchMinWidth is the smallest number of counters per row.
+	sketchMinWidth = 64
+
+	// sampleFactor times the width is the number of additions after
+	// which all counts are halved, so that old popularity fades.
+	sampleFactor = 10
+)
+
+// sketch is a count-min sketch estimating how often hashes were added.
+// 频率估计(count-min sketch)，定期减半以淡化旧的使用记录
+type sketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+}
+
+// add counts an occurrence of h. The sketch grows when size outgrows
+// it.
+func (s *sketch) add(h uint64, size int) {
+	if s.rows[0] == nil || size > len(s.rows[0]) {
+		s.grow(size)
+	}
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < maxCount {
+			*c++
+		}
+	}
+	s.additions++
+	if s.additions >= sampleFactor*len(s.rows[0]) {
+		s.halve()
+	}
+}
+
+// estimate returns the approximate number of occurrences of h.
+func (s *sketch) estimate(h uint64) uint8 {
+	if s.rows[0] == nil {
+		return 0
+	}
+	min := uint8(maxCount)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+func (s *sketch) index(h uint64, i int) uint64 {
+	// Double hashing derives a hash per row from the two halves of h.
+	return (h + uint64(i)*(h>>32|1)) & s.mask
+}
+
+// grow widens the rows to fit size, a power of two at a time. Since
+// an index into the wider rows masks more bits of the same hash, each
+// counter starts off from the one it was folded into before.
+func (s *sketch) grow(size int) {
+	width := len(s.rows[0])
+	if width == 0 {
+		width = sketchMinWidth
+	}
+	for width < size {
+		width *= 2
+	}
+	for i, old := range s.rows {
+		row := make([]uint8, width)
+		if len(old) > 0 {
+			for j := range row {
+				row[j] = old[j&(len(old)-1)]
+			}
+		}
+		s.rows[i] = row
+	}
+	s.mask = uint64(width - 1)
+}
+
+func (s *sketch) halve() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] /= 2
+		}
+	}
+	s.additions /= 2
+}
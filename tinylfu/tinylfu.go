@@ -0,0 +1,278 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tinylfu implements a W-TinyLFU cache.
+// tinylfu包实现W-TinyLFU缓存算法
+//
+// New keys enter a small LRU window. Evicting then picks between the
+// oldest key of the window and the oldest key of the main segmented
+// LRU, keeping whichever a frequency sketch estimates to be used more
+// often, so that keys used once rarely displace popular ones.
+// 新记录先进入窗口LRU，淘汰时通过频率估计在窗口与主缓存的候选之间选择
+package tinylfu
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+)
+
+const (
+	// windowRatio is the share of entries kept in the window.
+	windowRatio = 0.01
+
+	// protectedRatio is the share of the main entries kept in the
+	// protected segment.
+	protectedRatio = 0.8
+)
+
+// segments of the cache
+// 记录所在的分段
+const (
+	window = iota
+	probation
+	protected
+)
+
+// Cache is a W-TinyLFU cache of values of type V by keys of type K. It is
+// not safe for concurrent access.
+// Cache结构体是W-TinyLFU cache算法，并发访问不安全
+type Cache[K comparable, V any] struct {
+	// MaxEntries is the maximum number of cache entries before
+	// an item is evicted. Zero means no limit.
+	// 最大缓存数量，0代表无限制
+	MaxEntries int
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	// 缓存实体被清除时回调函数
+	OnEvicted func(key K, value V)
+
+	// 各分段的双向链表，头部为最近使用的记录
+	segs [3]*list.List
+	// map key为K类型 value为链表节点指针
+	cache map[K]*list.Element
+	// 使用频率估计
+	sketch sketch
+}
+
+// A Key may be any value that is comparable. See http://golang.org/ref/spec#Comparison_operators
+// Keys other than strings are hashed by their fmt.Sprint form.
+// 任何可比较的类型
+type Key interface{}
+
+// AnyCache is a Cache of keys and values of any type, as Cache was
+// before it had type parameters.
+// AnyCache 键和值为任意类型的Cache，兼容泛型之前的Cache
+type AnyCache = Cache[Key, interface{}]
+
+// 记录结构体
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+	hash  uint64
+	seg   int
+}
+
+// New creates a new AnyCache.
+// If maxEntries is zero, the cache has no limit and it's assumed
+// that eviction is done by the caller.
+// New 创建新的缓存实例
+// 如果 maxEntries为零，则缓存没有限制，淘汰缓存由调用者完成
+func New(maxEntries int) *AnyCache {
+	return NewCache[Key, interface{}](maxEntries)
+}
+
+// NewCache creates a new Cache of values of type V by keys of type K.
+// If maxEntries is zero, the cache has no limit.
+// NewCache 创建新的泛型缓存实例
+func NewCache[K comparable, V any](maxEntries int) *Cache[K, V] {
+	c := &Cache[K, V]{MaxEntries: maxEntries}
+	c.init()
+	return c
+}
+
+func (c *Cache[K, V]) init() {
+	for i := range c.segs {
+		c.segs[i] = list.New()
+	}
+	c.cache = make(map[K]*list.Element)
+}
+
+// Add adds a value to the cache.
+// Add 往缓存中添加一个值
+func (c *Cache[K, V]) Add(key K, value V) {
+	if c.cache == nil {
+		c.init()
+	}
+	if ele, ok := c.cache[key]; ok {
+		ele.Value.(*entry[K, V]).value = value
+		c.use(ele)
+		return
+	}
+	h := hash(key)
+	c.sketch.add(h, c.size())
+	c.cache[key] = c.segs[window].PushFront(&entry[K, V]{key, value, h, window})
+	// 窗口满时，最旧的记录进入主缓存的probation分段
+	if c.segs[window].Len() > c.limit(len(c.cache), windowRatio) {
+		c.move(c.segs[window].Back(), probation)
+	}
+	if c.MaxEntries != 0 && len(c.cache) > c.MaxEntries {
+		c.RemoveOldest()
+	}
+}
+
+// Get looks up a key's value from the cache.
+// Get 根据key查找value
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	ele, hit := c.cache[key]
+	if !hit {
+		// Misses count too: the key may be added next.
+		c.sketch.add(hash(key), c.size())
+		return
+	}
+	kv := ele.Value.(*entry[K, V])
+	c.use(ele)
+	return kv.value, true
+}
+
+// Remove removes the provided key from the cache.
+// Remove 移除指定key的缓存记录
+func (c *Cache[K, V]) Remove(key K) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveOldest removes either the oldest item of the window or that
+// of the main cache, whichever is estimated to be used less often.
+// RemoveOldest 在窗口与主缓存最旧的记录中，移除使用频率较低的一个
+func (c *Cache[K, V]) RemoveOldest() {
+	if c.cache == nil || len(c.cache) == 0 {
+		return
+	}
+	candidate := c.segs[window].Back()
+	victim := c.segs[probation].Back()
+	if victim == nil {
+		victim = c.segs[protected].Back()
+	}
+	switch {
+	case candidate == nil:
+		c.removeElement(victim)
+	case victim == nil:
+		c.removeElement(candidate)
+	case c.sketch.estimate(candidate.Value.(*entry[K, V]).hash) > c.sketch.estimate(victim.Value.(*entry[K, V]).hash):
+		c.removeElement(victim)
+	default:
+		c.removeElement(candidate)
+	}
+}
+
+// Peek returns the value of key without counting as a use of it.
+// Peek 根据key查找value，不计为一次使用
+func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
+	if ele, hit := c.cache[key]; hit {
+		return ele.Value.(*entry[K, V]).value, true
+	}
+	return
+}
+
+// Len returns the number of items in the cache.
+// 返回缓存记录数目
+func (c *Cache[K, V]) Len() int {
+	return len(c.cache)
+}
+
+// Clear purges all stored items from the cache.
+// 删除缓存所有记录
+func (c *Cache[K, V]) Clear() {
+	if c.OnEvicted != nil {
+		for _, e := range c.cache {
+			kv := e.Value.(*entry[K, V])
+			c.OnEvicted(kv.key, kv.value)
+		}
+	}
+	c.segs = [3]*list.List{}
+	c.cache = nil
+	c.sketch = sketch{}
+}
+
+// use records a use of the entry at ele. Entries used again in the
+// probation segment are promoted to the protected one.
+func (c *Cache[K, V]) use(ele *list.Element) {
+	kv := ele.Value.(*entry[K, V])
+	c.sketch.add(kv.hash, c.size())
+	if kv.seg != probation {
+		c.segs[kv.seg].MoveToFront(ele)
+		return
+	}
+	c.move(ele, protected)
+	main := len(c.cache) - c.segs[window].Len()
+	if c.segs[protected].Len() > c.limit(main, protectedRatio) {
+		c.move(c.segs[protected].Back(), probation)
+	}
+}
+
+// move moves the entry at ele to the front of segment seg.
+func (c *Cache[K, V]) move(ele *list.Element, seg int) {
+	kv := ele.Value.(*entry[K, V])
+	c.segs[kv.seg].Remove(ele)
+	kv.seg = seg
+	c.cache[kv.key] = c.segs[seg].PushFront(kv)
+}
+
+// size returns the number of entries the cache is sized for, which
+// is MaxEntries or, without a limit, the current number of entries.
+func (c *Cache[K, V]) size() int {
+	if c.MaxEntries != 0 {
+		return c.MaxEntries
+	}
+	return len(c.cache)
+}
+
+// limit returns ratio of n, but at least 1.
+func (c *Cache[K, V]) limit(n int, ratio float64) int {
+	if l := int(float64(n) * ratio); l > 0 {
+		return l
+	}
+	return 1
+}
+
+// 移除记录节点
+func (c *Cache[K, V]) removeElement(e *list.Element) {
+	kv := e.Value.(*entry[K, V])
+	c.segs[kv.seg].Remove(e)
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+func hash[K comparable](key K) uint64 {
+	h := fnv.New64a()
+	if s, ok := any(key).(string); ok {
+		h.Write([]byte(s))
+	} else {
+		fmt.Fprint(h, key)
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tinylfu
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	c := New(0)
+	c.Add("myKey", 1234)
+	if val, ok := c.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("Get(myKey) = %v, %v; want 1234, true", val, ok)
+	}
+	if _, ok := c.Get("nonsense"); ok {
+		t.Fatal("Get(nonsense) hit")
+	}
+	c.Add(struct{ a, b int }{1, 2}, 5)
+	if val, ok := c.Get(struct{ a, b int }{1, 2}); !ok || val != 5 {
+		t.Fatalf("Get(struct) = %v, %v; want 5, true", val, ok)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	c := New(0)
+	for i := 0; i < 10; i++ {
+		c.Add(i, i)
+		c.Get(i)
+	}
+	for i := 0; i < 10; i++ {
+		c.Remove(i)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("Len = %d; want 0", c.Len())
+	}
+	c.RemoveOldest() // must not panic on an empty cache
+}
+
+func TestEvict(t *testing.T) {
+	evictedKeys := make([]Key, 0)
+	c := New(20)
+	c.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("hot%d", i)
+		c.Add(key, 1)
+		for j := 0; j < 5; j++ {
+			c.Get(key)
+		}
+	}
+	// A scan of keys used once doesn't evict the popular ones.
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("myKey%d", i), 1234)
+	}
+	for i := 0; i < 10; i++ {
+		if _, ok := c.Get(fmt.Sprintf("hot%d", i)); !ok {
+			t.Errorf("scan evicted popular key hot%d", i)
+		}
+	}
+	if len(evictedKeys) != 90 {
+		t.Fatalf("got %d evicted keys; want 90", len(evictedKeys))
+	}
+}
+
+func TestSketch(t *testing.T) {
+	var s sketch
+	for i := 0; i < 5; i++ {
+		s.add(hash("five"), 100)
+	}
+	s.add(hash("one"), 100)
+	if n := s.estimate(hash("five")); n < 5 {
+		t.Errorf("estimate(five) = %d; want at least 5", n)
+	}
+	if n := s.estimate(hash("one")); n < 1 || n >= 5 {
+		t.Errorf("estimate(one) = %d; want 1 or a little more", n)
+	}
+	for i := 0; i < sampleFactor*len(s.rows[0]); i++ {
+		s.add(hash("other"), 100)
+	}
+	if n := s.estimate(hash("five")); n > 2 {
+		t.Errorf("estimate(five) after aging = %d; want at most 2", n)
+	}
+}
+
+func TestSketchGrow(t *testing.T) {
+	var s sketch
+	for i := 0; i < 5; i++ {
+		s.add(hash("five"), 10)
+	}
+	s.add(hash("other"), 1000)
+	if len(s.rows[0]) != 1024 {
+		t.Fatalf("width = %d; want 1024", len(s.rows[0]))
+	}
+	if n := s.estimate(hash("five")); n < 5 {
+		t.Errorf("estimate(five) after growing = %d; want at least 5", n)
+	}
+}
+
+func TestTyped(t *testing.T) {
+	var evicted []string
+	c := NewCache[string, int](0)
+	c.OnEvicted = func(key string, value int) {
+		evicted = append(evicted, key)
+	}
+	c.Add("a", 1)
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Fatalf("Peek(a) = %v, %v; want 1, true", v, ok)
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %v, %v; want 1, true", v, ok)
+	}
+	c.Remove("a")
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Fatalf("evicted %v; want [a]", evicted)
+	}
+}
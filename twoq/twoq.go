@@ -0,0 +1,247 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package twoq implements a 2Q cache.
+// twoq包实现2Q缓存算法
+//
+// New keys enter a FIFO queue of recent entries; keys used again, or
+// loaded again soon after being evicted from it, move to an LRU queue
+// of frequent entries. A scan over many keys used once thus only
+// evicts other recent entries.
+// 新记录先进入recent队列，再次使用的记录进入frequent队列，一次性扫描不会淘汰热点记录
+package twoq
+
+import "container/list"
+
+const (
+	// recentRatio is the share of entries kept in the recent queue
+	// before frequent entries are evicted.
+	recentRatio = 0.25
+
+	// ghostRatio is the number of evicted recent keys remembered,
+	// relative to the number of entries.
+	ghostRatio = 0.5
+)
+
+// Cache is a 2Q cache of values of type V by keys of type K. It is
+// not safe for concurrent access.
+// Cache结构体是2Q cache算法，并发访问不安全
+type Cache[K comparable, V any] struct {
+	// MaxEntries is the maximum number of cache entries before
+	// an item is evicted. Zero means no limit.
+	// 最大缓存数量，0代表无限制
+	MaxEntries int
+
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	// 缓存实体被清除时回调函数
+	OnEvicted func(key K, value V)
+
+	// 只使用过一次的记录，先进先出
+	recent *list.List
+	// 使用过多次的记录，最近最少使用顺序
+	frequent *list.List
+	// 最近从recent淘汰的key，不保存value
+	ghost *list.List
+	// map key为K类型 value为recent或frequent中的链表节点指针
+	cache map[K]*list.Element
+	// map key为K类型 value为ghost中的链表节点指针
+	ghosts map[K]*list.Element
+}
+
+// A Key may be any value that is comparable. See http://golang.org/ref/spec#Comparison_operators
+// 任何可比较的类型
+type Key interface{}
+
+// AnyCache is a Cache of keys and values of any type, as Cache was
+// before it had type parameters.
+// AnyCache 键和值为任意类型的Cache，兼容泛型之前的Cache
+type AnyCache = Cache[Key, interface{}]
+
+// 记录结构体
+type entry[K comparable, V any] struct {
+	key      K
+	value    V
+	frequent bool
+}
+
+// New creates a new AnyCache.
+// If maxEntries is zero, the cache has no limit and it's assumed
+// that eviction is done by the caller.
+// New 创建新的缓存实例
+// 如果 maxEntries为零，则缓存没有限制，淘汰缓存由调用者完成
+func New(maxEntries int) *AnyCache {
+	return NewCache[Key, interface{}](maxEntries)
+}
+
+// NewCache creates a new Cache of values of type V by keys of type K.
+// If maxEntries is zero, the cache has no limit.
+// NewCache 创建新的泛型缓存实例
+func NewCache[K comparable, V any](maxEntries int) *Cache[K, V] {
+	c := &Cache[K, V]{MaxEntries: maxEntries}
+	c.init()
+	return c
+}
+
+func (c *Cache[K, V]) init() {
+	c.recent = list.New()
+	c.frequent = list.New()
+	c.ghost = list.New()
+	c.cache = make(map[K]*list.Element)
+	c.ghosts = make(map[K]*list.Element)
+}
+
+// Add adds a value to the cache.
+// Add 往缓存中添加一个值
+func (c *Cache[K, V]) Add(key K, value V) {
+	if c.cache == nil {
+		c.init()
+	}
+	if ele, ok := c.cache[key]; ok {
+		ele.Value.(*entry[K, V]).value = value
+		c.use(ele)
+		return
+	}
+	// 最近被淘汰过的key直接进入frequent队列
+	if g, ok := c.ghosts[key]; ok {
+		c.ghost.Remove(g)
+		delete(c.ghosts, key)
+		c.cache[key] = c.frequent.PushFront(&entry[K, V]{key, value, true})
+	} else {
+		c.cache[key] = c.recent.PushFront(&entry[K, V]{key, value, false})
+	}
+	if c.MaxEntries != 0 && len(c.cache) > c.MaxEntries {
+		c.RemoveOldest()
+	}
+}
+
+// Get looks up a key's value from the cache.
+// Get 根据key查找value
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		c.use(ele)
+		return c.cache[key].Value.(*entry[K, V]).value, true
+	}
+	return
+}
+
+// Remove removes the provided key from the cache.
+// Remove 移除指定key的缓存记录
+func (c *Cache[K, V]) Remove(key K) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
+// RemoveOldest removes the oldest recent item from the cache, or
+// the least recently used frequent one once few recent items remain.
+// RemoveOldest 优先移除recent队列中最旧的记录
+func (c *Cache[K, V]) RemoveOldest() {
+	if c.cache == nil || len(c.cache) == 0 {
+		return
+	}
+	if c.recent.Len() > 0 && (c.frequent.Len() == 0 || c.recent.Len() > c.limit(recentRatio)) {
+		ele := c.recent.Back()
+		key := ele.Value.(*entry[K, V]).key
+		c.removeElement(ele)
+		// 记住被淘汰的key
+		c.ghosts[key] = c.ghost.PushFront(key)
+		for c.ghost.Len() > c.limit(ghostRatio) {
+			g := c.ghost.Back()
+			c.ghost.Remove(g)
+			delete(c.ghosts, g.Value.(K))
+		}
+		return
+	}
+	c.removeElement(c.frequent.Back())
+}
+
+// Peek returns the value of key without counting as a use of it.
+// Peek 根据key查找value，不计为一次使用
+func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
+	if ele, hit := c.cache[key]; hit {
+		return ele.Value.(*entry[K, V]).value, true
+	}
+	return
+}
+
+// Len returns the number of items in the cache.
+// 返回缓存记录数目
+func (c *Cache[K, V]) Len() int {
+	return len(c.cache)
+}
+
+// Clear purges all stored items from the cache.
+// 删除缓存所有记录
+func (c *Cache[K, V]) Clear() {
+	if c.OnEvicted != nil {
+		for _, e := range c.cache {
+			kv := e.Value.(*entry[K, V])
+			c.OnEvicted(kv.key, kv.value)
+		}
+	}
+	c.recent = nil
+	c.frequent = nil
+	c.ghost = nil
+	c.cache = nil
+	c.ghosts = nil
+}
+
+// use records a use of the entry at ele, moving it to the front of
+// the frequent queue.
+func (c *Cache[K, V]) use(ele *list.Element) {
+	kv := ele.Value.(*entry[K, V])
+	if kv.frequent {
+		c.frequent.MoveToFront(ele)
+		return
+	}
+	c.recent.Remove(ele)
+	kv.frequent = true
+	c.cache[kv.key] = c.frequent.PushFront(kv)
+}
+
+// limit returns ratio of the cache's size, which is MaxEntries or,
+// without a limit, the current number of entries.
+func (c *Cache[K, V]) limit(ratio float64) int {
+	size := c.MaxEntries
+	if size == 0 {
+		size = len(c.cache)
+	}
+	if n := int(float64(size) * ratio); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// 移除记录节点
+func (c *Cache[K, V]) removeElement(e *list.Element) {
+	kv := e.Value.(*entry[K, V])
+	if kv.frequent {
+		c.frequent.Remove(e)
+	} else {
+		c.recent.Remove(e)
+	}
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
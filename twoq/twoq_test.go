@@ -0,0 +1,99 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twoq
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	c := New(0)
+	c.Add("myKey", 1234)
+	for i := 0; i < 2; i++ { // once from the recent queue, once from frequent
+		if val, ok := c.Get("myKey"); !ok || val != 1234 {
+			t.Fatalf("Get(myKey) = %v, %v; want 1234, true", val, ok)
+		}
+	}
+	if _, ok := c.Get("nonsense"); ok {
+		t.Fatal("Get(nonsense) hit")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	c := New(0)
+	c.Add("recent", 1)
+	c.Add("frequent", 2)
+	c.Get("frequent")
+	c.Remove("recent")
+	c.Remove("frequent")
+	if c.Len() != 0 {
+		t.Fatalf("Len = %d; want 0", c.Len())
+	}
+	// Removed keys are not remembered as evicted.
+	c.Add("recent", 1)
+	if c.recent.Len() != 1 {
+		t.Fatal("removed key not re-added to the recent queue")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	evictedKeys := make([]Key, 0)
+	c := New(20)
+	c.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	c.Add("hot", 1)
+	c.Get("hot")
+	// A scan only evicts keys seen once.
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("myKey%d", i), 1234)
+	}
+	if _, ok := c.Get("hot"); !ok {
+		t.Fatal("scan evicted frequently used key")
+	}
+	if len(evictedKeys) != 81 {
+		t.Fatalf("got %d evicted keys; want 81", len(evictedKeys))
+	}
+	if evictedKeys[0] != Key("myKey0") {
+		t.Fatalf("got %v in first evicted key; want %s", evictedKeys[0], "myKey0")
+	}
+
+	// A key loaded again soon after its eviction is kept as frequent.
+	c.Add("myKey79", 1234)
+	if !c.cache["myKey79"].Value.(*entry[Key, interface{}]).frequent {
+		t.Error("recently evicted key not added to the frequent queue")
+	}
+}
+
+func TestTyped(t *testing.T) {
+	var evicted []string
+	c := NewCache[string, int](2)
+	c.OnEvicted = func(key string, value int) {
+		evicted = append(evicted, key)
+	}
+	c.Add("a", 1)
+	c.Add("b", 2)
+	// Peek is not a use, so "a" stays in the recent queue.
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Fatalf("Peek(a) = %v, %v; want 1, true", v, ok)
+	}
+	c.Add("c", 3)
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Fatalf("evicted %v; want [a]", evicted)
+	}
+}